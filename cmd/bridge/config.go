@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sjdaws/vsphere-bridge/internal/authentication"
+	"github.com/sjdaws/vsphere-bridge/internal/certificate"
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/credentials"
+	"github.com/sjdaws/vsphere-bridge/internal/firewall"
+	"github.com/sjdaws/vsphere-bridge/internal/policy"
+	"github.com/sjdaws/vsphere-bridge/internal/quota"
+	"github.com/sjdaws/vsphere-bridge/internal/vsphere"
+	"github.com/sjdaws/vsphere-bridge/internal/vsphere/passthrough"
+	"github.com/sjdaws/vsphere-bridge/internal/vsphere/vms/power"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+const configUsageText = `
+usage: %s config <validate|show> [OPTIONS]
+
+Inspect configuration without starting the bridge, accepts the same options and environment variables as the bridge
+
+Commands:
+
+  show        Show the effective configuration and the source of each value, secrets are redacted
+  validate    Validate configuration, exits with a non-zero status if configuration is invalid
+
+`
+
+// runConfig run the config subcommand.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		fmt.Printf(configUsageText, os.Args[0])
+
+		return nil
+	}
+
+	switch args[0] {
+	case "show":
+		return showConfig(args[1:])
+	case "validate":
+		return validateConfig(args[1:])
+	default:
+		return errors.New("unknown config command %s, run %s config --help for more information", args[0], os.Args[0])
+	}
+}
+
+// showConfig print the effective value and source of each configuration key.
+func showConfig(args []string) error {
+	_, settings, err := configuration.Inspect(args)
+	if err != nil {
+		return errors.Wrap(err, "invalid configuration")
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(writer, "KEY\tVALUE\tSOURCE")
+
+	for _, setting := range settings {
+		_, _ = fmt.Fprintf(writer, "%s\t%s\t%s\n", setting.Key, setting.Value, setting.Source)
+	}
+
+	return writer.Flush()
+}
+
+// validateConfig resolve configuration and load the files it references.
+func validateConfig(args []string) error {
+	config, _, err := configuration.Inspect(args)
+	if err != nil {
+		return errors.Wrap(err, "invalid configuration")
+	}
+
+	logger := logging.Default()
+	server := echo.New()
+
+	_, err = firewall.New(config, logger, server)
+	if err != nil {
+		return errors.Wrap(err, "invalid access control")
+	}
+
+	if config.TLSCertFile != "" {
+		_, err = certificate.New(config, logger)
+		if err != nil {
+			return errors.Wrap(err, "invalid tls configuration")
+		}
+	}
+
+	// Loads the oidc key set so an unreachable issuer or unusable keys are reported
+	_, err = authentication.New(config, logger, server)
+	if err != nil {
+		return errors.Wrap(err, "invalid authentication")
+	}
+
+	_, err = policy.New(config.PolicyFile, server)
+	if err != nil {
+		return errors.Wrap(err, "invalid policy")
+	}
+
+	store, err := credentials.New(config, logger)
+	if err != nil {
+		return errors.Wrap(err, "invalid credentials")
+	}
+
+	_, err = vsphere.New(config, store, logger)
+	if err != nil {
+		return errors.Wrap(err, "invalid vsphere configuration")
+	}
+
+	_, err = quota.New(config, logger)
+	if err != nil {
+		return errors.Wrap(err, "invalid power limits")
+	}
+
+	_, err = new(power.Power).Prepare(config)
+	if err != nil {
+		return errors.Wrap(err, "invalid power on queue")
+	}
+
+	_, err = new(passthrough.Passthrough).Prepare(config)
+	if err != nil {
+		return errors.Wrap(err, "invalid api passthrough")
+	}
+
+	fmt.Println("configuration is valid")
+
+	return nil
+}
@@ -1,45 +1,247 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
-	"net/http"
+	"net"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
+	"github.com/sjdaws/vsphere-bridge/internal/authentication"
+	"github.com/sjdaws/vsphere-bridge/internal/certificate"
 	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/credentials"
+	"github.com/sjdaws/vsphere-bridge/internal/firewall"
+	"github.com/sjdaws/vsphere-bridge/internal/health"
+	"github.com/sjdaws/vsphere-bridge/internal/listener"
+	"github.com/sjdaws/vsphere-bridge/internal/policy"
+	"github.com/sjdaws/vsphere-bridge/internal/problem"
+	"github.com/sjdaws/vsphere-bridge/internal/quota"
 	"github.com/sjdaws/vsphere-bridge/internal/vsphere"
+	"github.com/sjdaws/vsphere-bridge/internal/vsphere/passthrough"
 	"github.com/sjdaws/vsphere-bridge/internal/vsphere/vms/power"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
 	"github.com/sjdaws/vsphere-bridge/pkg/logging"
 	"github.com/sjdaws/vsphere-bridge/pkg/notifier"
+	"github.com/sjdaws/vsphere-bridge/pkg/systemd"
 )
 
 const usageText = `
-usage: %s [OPTIONS]
+usage: %s [COMMAND] [OPTIONS]
 
 Perform vsphere REST actions in a single call
 
+Commands:
+
+  config      Validate or show configuration, run %[1]s config --help for more information
+  secrets     Encrypt configuration values, run %[1]s secrets encrypt --help for more information
+  version     Show the bridge version
+
 Options:
 
-  --fqdn string      The fqdn of the target vsphere instance including scheme, e.g. http://vsphere.local
-  --insecure bool    Allow insecure SSL connections to vsphere instance
-  --port int         The port to run the bridge on, defaults to 8000
+  --admin-allow string        Comma separated addresses or networks allowed to access admin endpoints
+  --admin-bind-address string Address to bind the admin listener to, defaults to --bind-address
+  --admin-deny string         Comma separated addresses or networks denied access to admin endpoints
+  --admin-port int            Port to serve health and admin endpoints on instead of --port
+  --api-passthrough string    Comma separated METHOD /path patterns which can be sent to the vsphere api through /api
+  --basic-fallback bool       Allow basic authorization passthrough when bearer authentication is configured
+  --bind-address string       Address to bind the bridge to, defaults to all addresses
+  --breaker-cooldown string   Time calls to vsphere are rejected for once the circuit breaker opens, defaults to 30s
+  --breaker-threshold int     Consecutive vsphere failures before the circuit breaker opens, defaults to 5, 0 disables
+  --config string             Path to a YAML or TOML configuration file
+  --config-reload-interval string
+                              Interval between checks for a changed configuration or policy file, defaults to 30s, 0 disables
+  --credentials-file string   Path to an age encrypted JSON file containing vsphere username and password
+  --credentials-key-file string
+                              Path to an age identity used to decrypt the credentials file
+  --credentials-reload-interval string
+                              Interval between checks for changed credentials, defaults to 30s, 0 disables
+  --debug bool                Include error traces in error responses
+  --fqdn string               The fqdn of the target vsphere instance including scheme, e.g. http://vsphere.local
+  --health-allow string       Comma separated addresses or networks allowed to access health endpoints
+  --health-check-interval string
+                              Duration vsphere readiness check results are cached for, defaults to 15s
+  --health-deny string        Comma separated addresses or networks denied access to health endpoints
+  --insecure bool             Allow insecure SSL connections to vsphere instance
+  --lockout-duration string   Initial lockout after repeated basic authentication failures, defaults to 1m
+  --lockout-max-duration string
+                              Maximum lockout after repeated basic authentication failures, defaults to 1h
+  --lockout-threshold int     Basic authentication failures before locking out a username or address, defaults to 5, 0 disables
+  --oidc-audience string      Audience bearer tokens must be issued for, required if --oidc-issuer is set
+  --oidc-issuer string        Issuer of bearer tokens, enables bearer authentication
+  --oidc-jwks-file string     Path to a JWKS used to verify bearer tokens, for offline setups
+  --oidc-jwks-url string      URL of the JWKS used to verify bearer tokens, discovered from the issuer if not set
+  --oidc-name-claim string    Bearer token claim containing the client name, defaults to sub
+  --oidc-role-mapping string  Comma separated claim=role pairs used to map claim values to roles
+  --oidc-roles-claim string   Bearer token claim containing client roles, defaults to roles
+  --password-file string      Path to a file containing the password for vsphere account with API access
+  --policy-file string        Path to a file containing CEL policy rules evaluated before power actions
+  --port int                  The port to run the bridge on, defaults to 8000
+  --power-allow string        Comma separated addresses or networks allowed to access power endpoints
+  --power-deny string         Comma separated addresses or networks denied access to power endpoints
+  --power-limits string       Comma separated limits on power actions per client or virtual machine, e.g. client:reset=10/1h
+  --power-on-concurrency int
+                              Maximum virtual machines powered on at once, further power ons are queued, defaults to 0 which is unlimited
+  --power-on-priorities string
+                              Comma separated name=priority pairs, higher priority virtual machines are powered on first, names can contain wildcards
+  --power-on-stagger string   Minimum delay between virtual machines being powered on, defaults to 0s
+  --quota-file string         Path to a file daily power limit counters are persisted to across restarts
+  --retry-attempts int        Attempts made for vsphere calls which fail with a transient error, defaults to 3, 1 disables retries
+  --retry-backoff string      Delay before the first retry of a vsphere call, doubled for each retry, defaults to 500ms
+  --retry-timeout string      Maximum time spent on a vsphere call including retries, defaults to 60s
+  --secrets-key-file string   Path to an age identity used to decrypt encrypted configuration values
+  --shutdown-timeout string   Maximum time to wait for in-flight requests when shutting down, defaults to 25s
+  --signing-secret string     Secret used to create pre-signed URLs, enables pre-signed URLs
+  --tls-cert-file string      Path to a PEM encoded certificate, enables HTTPS when set with --tls-key-file
+  --tls-client-ca-file string Path to PEM encoded certificate authorities used to verify client certificates
+  --tls-client-cert-required bool
+                              Reject connections which don't present a valid client certificate
+  --tls-key-file string       Path to the PEM encoded private key for --tls-cert-file
+  --tls-min-version string    Minimum TLS version, one of 1.0, 1.1, 1.2, 1.3, defaults to 1.2
+  --trusted-proxies string    Comma separated addresses or networks of proxies trusted to set X-Forwarded-For
+  --unix-socket string        Path to a unix socket to serve the bridge on in addition to --port
+  --unix-socket-trusted bool  Allow unix socket clients through address allow and deny lists
+  --username-file string      Path to a file containing the username for vsphere account with API access
+  --vsphere-max-concurrency int
+                              Maximum concurrent calls to vsphere, defaults to 8, 0 disables
+  --vsphere-rate-burst int    Calls to vsphere allowed in a burst above the rate limit, defaults to 20
+  --vsphere-proxy string      Proxy used to reach vsphere, a url or comma separated host=url pairs, defaults to HTTPS_PROXY
+  --vsphere-rate-limit int    Calls to vsphere allowed per second, defaults to 10, 0 disables
+  --webhook-secret string     Secret used to verify HMAC signatures sent by webhooks
+  --webhook-signature-header string
+                              Header containing HMAC signatures in addition to X-Hub-Signature-256, defaults to X-Signature
 
 Environment variables:
 
+  ADMIN_ALLOW string         Comma separated addresses or networks allowed to access admin endpoints
+  ADMIN_BIND_ADDRESS string  Address to bind the admin listener to, defaults to BRIDGE_BIND_ADDRESS
+  ADMIN_DENY string          Comma separated addresses or networks denied access to admin endpoints
+  ADMIN_PORT int             Port to serve health and admin endpoints on instead of BRIDGE_PORT
+  API_PASSTHROUGH string     Comma separated METHOD /path patterns which can be sent to the vsphere api through /api
   ALLOW_INSECURE string      Allow insecure SSL connections to vsphere instance
+  AUTH_BASIC_FALLBACK bool   Allow basic authorization passthrough when bearer authentication is configured
+  BRIDGE_BIND_ADDRESS string Address to bind the bridge to, defaults to all addresses
+  BRIDGE_CONFIG string       Path to a YAML or TOML configuration file
+  BRIDGE_DEBUG bool          Include error traces in error responses
   BRIDGE_PORT int			 The port to run the bridge on, defaults to 8000
+  BRIDGE_UNIX_SOCKET string  Path to a unix socket to serve the bridge on in addition to BRIDGE_PORT
+  BRIDGE_UNIX_SOCKET_TRUSTED bool
+                             Allow unix socket clients through address allow and deny lists
+  BREAKER_COOLDOWN string    Time calls to vsphere are rejected for once the circuit breaker opens, defaults to 30s
+  BREAKER_THRESHOLD int      Consecutive vsphere failures before the circuit breaker opens, defaults to 5, 0 disables
+  CONFIG_RELOAD_INTERVAL string
+                             Interval between checks for a changed configuration or policy file, defaults to 30s, 0 disables
+  CREDENTIALS_KEY string     Age identity used to decrypt the credentials file
+  CREDENTIALS_KEY_FILE string
+                             Path to an age identity used to decrypt the credentials file
+  CREDENTIALS_RELOAD_INTERVAL string
+                             Interval between checks for changed credentials, defaults to 30s, 0 disables
+  HEALTH_ALLOW string        Comma separated addresses or networks allowed to access health endpoints
+  HEALTH_CHECK_INTERVAL string
+                             Duration vsphere readiness check results are cached for, defaults to 15s
+  HEALTH_DENY string         Comma separated addresses or networks denied access to health endpoints
+  LOCKOUT_DURATION string    Initial lockout after repeated basic authentication failures, defaults to 1m
+  LOCKOUT_MAX_DURATION string
+                             Maximum lockout after repeated basic authentication failures, defaults to 1h
+  LOCKOUT_THRESHOLD int      Basic authentication failures before locking out a username or address, defaults to 5, 0 disables
+  OIDC_AUDIENCE string       Audience bearer tokens must be issued for, required if OIDC_ISSUER is set
+  OIDC_ISSUER string         Issuer of bearer tokens, enables bearer authentication
+  OIDC_JWKS_FILE string      Path to a JWKS used to verify bearer tokens, for offline setups
+  OIDC_JWKS_URL string       URL of the JWKS used to verify bearer tokens, discovered from the issuer if not set
+  OIDC_NAME_CLAIM string     Bearer token claim containing the client name, defaults to sub
+  OIDC_ROLE_MAPPING string   Comma separated claim=role pairs used to map claim values to roles
+  OIDC_ROLES_CLAIM string    Bearer token claim containing client roles, defaults to roles
+  POLICY_FILE string         Path to a file containing CEL policy rules evaluated before power actions
+  POWER_ALLOW string         Comma separated addresses or networks allowed to access power endpoints
+  POWER_DENY string          Comma separated addresses or networks denied access to power endpoints
+  POWER_LIMITS string        Comma separated limits on power actions per client or virtual machine, e.g. client:reset=10/1h
+  POWER_ON_CONCURRENCY int   Maximum virtual machines powered on at once, further power ons are queued, defaults to 0 which is unlimited
+  POWER_ON_PRIORITIES string
+                             Comma separated name=priority pairs, higher priority virtual machines are powered on first, names can contain wildcards
+  POWER_ON_STAGGER string    Minimum delay between virtual machines being powered on, defaults to 0s
+  QUOTA_FILE string          Path to a file daily power limit counters are persisted to across restarts
+  RETRY_ATTEMPTS int         Attempts made for vsphere calls which fail with a transient error, defaults to 3, 1 disables retries
+  RETRY_BACKOFF string       Delay before the first retry of a vsphere call, doubled for each retry, defaults to 500ms
+  RETRY_TIMEOUT string       Maximum time spent on a vsphere call including retries, defaults to 60s
+  SECRETS_KEY string         Age identity used to decrypt encrypted configuration values
+  SECRETS_KEY_FILE string    Path to an age identity used to decrypt encrypted configuration values
+  SHUTDOWN_TIMEOUT string    Maximum time to wait for in-flight requests when shutting down, defaults to 25s
+  SIGNING_SECRET string      Secret used to create pre-signed URLs, enables pre-signed URLs
+  TLS_CERT_FILE string       Path to a PEM encoded certificate, enables HTTPS when set with TLS_KEY_FILE
+  TLS_CLIENT_CA_FILE string  Path to PEM encoded certificate authorities used to verify client certificates
+  TLS_CLIENT_CERT_REQUIRED bool
+                             Reject connections which don't present a valid client certificate
+  TLS_KEY_FILE string        Path to the PEM encoded private key for TLS_CERT_FILE
+  TLS_MIN_VERSION string     Minimum TLS version, one of 1.0, 1.1, 1.2, 1.3, defaults to 1.2
+  TRUSTED_PROXIES string     Comma separated addresses or networks of proxies trusted to set X-Forwarded-For
+  VSPHERE_CREDENTIALS_FILE string
+                             Path to an age encrypted JSON file containing vsphere username and password
   VSPHERE_FQDN string        The fqdn of the target vsphere instance including scheme, e.g. http://vsphere.local
+  VSPHERE_MAX_CONCURRENCY int
+                             Maximum concurrent calls to vsphere, defaults to 8, 0 disables
   VSPHERE_PASSWORD string    Password for vsphere account with API access
+  VSPHERE_PASSWORD_FILE string
+                             Path to a file containing the password for vsphere account with API access
+  VSPHERE_PROXY string       Proxy used to reach vsphere, a url or comma separated host=url pairs, defaults to HTTPS_PROXY
+  VSPHERE_RATE_BURST int     Calls to vsphere allowed in a burst above the rate limit, defaults to 20
+  VSPHERE_RATE_LIMIT int     Calls to vsphere allowed per second, defaults to 10, 0 disables
   VSPHERE_USERNAME string    Username for vsphere account with API access
+  VSPHERE_USERNAME_FILE string
+                             Path to a file containing the username for vsphere account with API access
+  WEBHOOK_SECRET string      Secret used to verify HMAC signatures sent by webhooks
+  WEBHOOK_SIGNATURE_HEADER string
+                             Header containing HMAC signatures in addition to X-Hub-Signature-256, defaults to X-Signature
 
 FQDN is mandatory, the rest of the parameters are optional.
 
-Option will be used if both option and environment variable are passed for the same parameter.
+Option will be used if both option and environment variable are passed for the same parameter, environment
+variables override values from the configuration file.
+
+Configuration is reloaded without a restart on SIGHUP or when the configuration or policy file changes.
+
+Values prefixed with age: are decrypted at startup, run %[1]s secrets encrypt --help to create encrypted values.
 
 `
 
+const (
+	// exitOK the bridge shut down after completing in-flight requests.
+	exitOK = 0
+
+	// exitFailure the bridge couldn't start, usually due to invalid configuration.
+	exitFailure = 1
+
+	// exitServe a listener failed while serving requests.
+	exitServe = 2
+
+	// exitShutdownTimeout requests were still in progress when the shutdown timeout expired.
+	exitShutdownTimeout = 3
+)
+
+// commands subcommands which run instead of the bridge.
+var commands = map[string]func(args []string) error{
+	"config":  runConfig,
+	"secrets": runSecrets,
+	"version": runVersion,
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			err := command(os.Args[2:])
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+
+			os.Exit(0)
+		}
+	}
+
 	// Check if help is requested
 	for _, arg := range os.Args {
 		if arg == "-h" || arg == "--help" {
@@ -55,29 +257,228 @@ func main() {
 		logger.Error(err)
 
 		fmt.Printf(usageText, os.Args[0])
-		os.Exit(0)
+		os.Exit(exitFailure)
 	}
 
-	var notify *notifier.Notifier
-	if config.NotifyURL != "" {
-		notify = notifier.New([]string{config.NotifyURL})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	notify := notifier.New([]string{config.NotifyURL})
+
+	problems := problem.New(config, logger)
+
+	server := newServer(problems)
+
+	// Health and admin endpoints move to their own listener when an admin port is configured
+	admin := server
+	if config.AdminPort != "" {
+		admin = newServer(problems)
 	}
 
-	server := echo.New()
-	server.HTTPErrorHandler = func(err error, ctx echo.Context) {
-		logger.Error(err)
-		_ = ctx.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	access, err := firewall.New(config, logger, server, admin)
+	if err != nil {
+		fatal(logger, err)
+	}
+
+	auth, err := authentication.New(config, logger, admin, access.Middleware(firewall.Admin))
+	if err != nil {
+		fatal(logger, err)
 	}
 
-	server.GET("/health", func(ctx echo.Context) error {
-		return ctx.JSON(http.StatusOK, map[string]string{"status": "ok"})
+	var signed echo.MiddlewareFunc
+	if auth.Signing() {
+		signed = auth.RequireSignature
+	}
+
+	rules, err := policy.New(config.PolicyFile, admin, access.Middleware(firewall.Admin), auth.Middleware)
+	if err != nil {
+		fatal(logger, err)
+	}
+
+	store, err := credentials.New(config, logger)
+	if err != nil {
+		fatal(logger, err)
+	}
+
+	go store.Watch(ctx)
+
+	limits, err := quota.New(config, logger)
+	if err != nil {
+		fatal(logger, err)
+	}
+
+	api, err := vsphere.New(config, store, logger)
+	if err != nil {
+		fatal(logger, err)
+	}
+
+	health.New(api, admin, access.Middleware(firewall.Health))
+
+	// Discover the vsphere version up front so capability checks don't delay the first request
+	go api.About(ctx)
+	actions, err := power.New(config, api, notify, rules, limits, server, signed, access.Middleware(firewall.Power), auth.Middleware)
+	if err != nil {
+		fatal(logger, err)
+	}
+
+	forwarder, err := passthrough.New(config, api, logger, server, access.Middleware(firewall.Power), auth.Middleware)
+	if err != nil {
+		fatal(logger, err)
+	}
+
+	reloader := configuration.NewReloader(config, logger, notify)
+	reloader.OnReload(func(config *configuration.Configuration) (func(), error) {
+		return func() { notify.Update([]string{config.NotifyURL}) }, nil
+	})
+	reloader.OnReload(problems.Prepare)
+	reloader.OnReload(access.Prepare)
+	reloader.OnReload(auth.Prepare)
+	reloader.OnReload(func(config *configuration.Configuration) (func(), error) {
+		return rules.Prepare(config.PolicyFile)
 	})
+	reloader.OnReload(limits.Prepare)
+	reloader.OnReload(actions.Prepare)
+	reloader.OnReload(forwarder.Prepare)
+	reloader.OnReload(store.Prepare)
+	reloader.OnReload(api.Prepare)
+
+	go reloader.Watch(ctx)
+
+	var tlsConfig *tls.Config
+	if config.TLSCertFile != "" {
+		certificates, err := certificate.New(config, logger)
+		if err != nil {
+			fatal(logger, err)
+		}
 
-	api := vsphere.New(config, logger)
-	power.New(*api, notify, server)
+		tlsConfig = certificates.Config()
+	}
+
+	listeners, err := listen(config, logger, server, admin, tlsConfig)
+	if err != nil {
+		fatal(logger, err)
+	}
+
+	terminate := make(chan os.Signal, 1)
+	signal.Notify(terminate, syscall.SIGINT, syscall.SIGTERM)
+
+	serving := make(chan error, 1)
+	go func() {
+		serving <- listeners.Serve()
+	}()
+
+	notifySystemd(logger, systemd.Ready)
+
+	go watchdog(ctx, logger, api)
+
+	select {
+	case err = <-serving:
+		logger.Error(err)
+		os.Exit(exitServe)
+	case received := <-terminate:
+		logger.Info("received %s, shutting down", received)
+	}
+
+	// A second signal exits immediately
+	signal.Stop(terminate)
+	cancel()
+
+	os.Exit(shutdown(logger, reloader.Current().ShutdownTimeout, listeners, actions, api))
+}
+
+// listen on configured addresses, sockets passed by systemd are used instead of binding the bridge and admin ports.
+func listen(config *configuration.Configuration, logger logging.Logger, server *echo.Echo, admin *echo.Echo, tlsConfig *tls.Config) (*listener.Listeners, error) {
+	listeners := listener.New(logger)
+
+	activated, err := systemd.Listeners()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to use sockets passed by systemd")
+	}
+
+	bindServer := true
+	bindAdmin := admin != server
+
+	for _, socket := range activated {
+		handler := server
+		if socket.Name == "admin" && bindAdmin {
+			handler = admin
+			bindAdmin = false
+		} else {
+			bindServer = false
+		}
+
+		socketTLS := tlsConfig
+		if socket.Listener.Addr().Network() == "unix" {
+			socketTLS = nil
+		}
+
+		listeners.Attach(socket.Listener, handler, socketTLS)
+	}
+
+	if bindServer {
+		err = listeners.Add("tcp", net.JoinHostPort(config.BindAddress, config.Port), server, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.UnixSocket != "" {
+		err = listeners.Add("unix", config.UnixSocket, server, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if bindAdmin {
+		err = listeners.Add("tcp", net.JoinHostPort(config.AdminBindAddress, config.AdminPort), admin, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return listeners, nil
+}
+
+// fatal log an error which prevents the bridge from starting and exit.
+func fatal(logger logging.Logger, err error) {
+	logger.Error(err)
+	os.Exit(exitFailure)
+}
+
+// shutdown stop accepting requests, wait for in-flight power operations and log out of vsphere, returning the exit code.
+func shutdown(logger logging.Logger, timeout time.Duration, listeners *listener.Listeners, actions *power.Power, api *vsphere.Vsphere) int {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	err = server.Start(":" + config.Port)
+	notifySystemd(logger, systemd.Stopping)
+
+	code := exitOK
+
+	err := listeners.Shutdown(ctx)
 	if err != nil {
-		logger.Fatal(err)
+		logger.Error(errors.Wrap(err, "requests were still in progress after %s", timeout))
+		code = exitShutdownTimeout
 	}
+
+	err = actions.Drain(ctx)
+	if err != nil {
+		logger.Error(errors.Wrap(err, "power operations were still in progress after %s", timeout))
+		code = exitShutdownTimeout
+	}
+
+	api.Close()
+
+	logger.Info("shutdown complete")
+
+	return code
+}
+
+// newServer create an echo server which reports errors as problem details.
+func newServer(problems *problem.Handler) *echo.Echo {
+	server := echo.New()
+	server.HideBanner = true
+
+	problems.Attach(server)
+
+	return server
 }
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sjdaws/vsphere-bridge/internal/secrets"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+const secretsUsageText = `
+usage: %s secrets encrypt [OPTIONS] [VALUE]
+
+Encrypt a value for use in configuration, if VALUE is not passed it is read from stdin
+
+Options:
+
+  --key-file string     Path to an age identity, the value is encrypted for this identity if no recipient is passed
+  --recipient string    Age recipient to encrypt the value for, may be passed multiple times
+
+Environment variables:
+
+  SECRETS_KEY string         Age identity, the value is encrypted for this identity if no recipient is passed
+  SECRETS_KEY_FILE string    Path to an age identity, the value is encrypted for this identity if no recipient is passed
+
+`
+
+// recipientList flag which can be passed multiple times.
+type recipientList []string
+
+// Set add a recipient to the list.
+func (r *recipientList) Set(value string) error {
+	*r = append(*r, value)
+
+	return nil
+}
+
+// String representation of the list.
+func (r *recipientList) String() string {
+	return strings.Join(*r, ",")
+}
+
+// runSecrets run the secrets subcommand.
+func runSecrets(args []string) error {
+	if len(args) > 0 && (args[0] == "-h" || args[0] == "--help") {
+		fmt.Printf(secretsUsageText, os.Args[0])
+
+		return nil
+	}
+
+	if len(args) == 0 || args[0] != "encrypt" {
+		return errors.New("unknown secrets command, run %s secrets encrypt --help for more information", os.Args[0])
+	}
+
+	flags := flag.NewFlagSet("secrets encrypt", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Printf(secretsUsageText, os.Args[0])
+	}
+
+	var recipients recipientList
+	flags.Var(&recipients, "recipient", "age recipient")
+	keyFile := flags.String("key-file", os.Getenv("SECRETS_KEY_FILE"), "path to age identity")
+
+	err := flags.Parse(args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		// Usage has already been printed
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "invalid arguments")
+	}
+
+	var plaintext string
+	if flags.NArg() > 0 {
+		plaintext = strings.Join(flags.Args(), " ")
+	} else {
+		input, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return errors.Wrap(err, "unable to read value from stdin")
+		}
+
+		plaintext = strings.TrimRight(string(input), "\r\n")
+	}
+
+	if plaintext == "" {
+		return errors.New("a value to encrypt is required")
+	}
+
+	identities, err := secrets.Identities(os.Getenv("SECRETS_KEY"), *keyFile)
+	if err != nil && len(recipients) == 0 {
+		return errors.Wrap(err, "unable to load secrets key")
+	}
+
+	parsed, err := secrets.Recipients(recipients, identities)
+	if err != nil {
+		return errors.Wrap(err, "unable to determine recipients")
+	}
+
+	encrypted, err := secrets.EncryptValue(plaintext, parsed)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(encrypted)
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/sjdaws/vsphere-bridge/internal/vsphere"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+	"github.com/sjdaws/vsphere-bridge/pkg/systemd"
+)
+
+// notifySystemd send a state to systemd if the bridge is run as a notify service.
+func notifySystemd(logger logging.Logger, state string) {
+	_, err := systemd.Notify(state)
+	if err != nil {
+		logger.Warn(errors.Wrap(err, "unable to notify systemd"))
+	}
+}
+
+// watchdog notify systemd while vsphere is ready, so systemd restarts the bridge if vsphere can't be used.
+func watchdog(ctx context.Context, logger logging.Logger, api *vsphere.Vsphere) {
+	interval, enabled := systemd.WatchdogInterval()
+	if !enabled {
+		return
+	}
+
+	// The bridge is ready when it notifies systemd at startup, notifications are withheld once a check fails
+	var ready atomic.Bool
+	ready.Store(true)
+
+	// Checks run in the background so a slow check doesn't delay notifications while the last result is healthy
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			checkCtx, cancel := context.WithTimeout(ctx, interval)
+			ready.Store(api.Status(checkCtx).Ready)
+			cancel()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !ready.Load() {
+				logger.Warn("vsphere is not ready, withholding systemd watchdog notification")
+				notifySystemd(logger, "STATUS=vsphere is not ready")
+
+				continue
+			}
+
+			notifySystemd(logger, "STATUS=ready")
+			notifySystemd(logger, systemd.Watchdog)
+		}
+	}
+}
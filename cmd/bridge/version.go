@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// version of the bridge, set at build time with -ldflags "-X main.version=<version>".
+var version = "dev"
+
+// runVersion print the version of the bridge.
+func runVersion(_ []string) error {
+	revision := "unknown"
+
+	info, ok := debug.ReadBuildInfo()
+	if ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				revision = setting.Value
+			}
+		}
+	}
+
+	fmt.Printf("vsphere-bridge %s (revision %s, %s %s/%s)\n", version, revision, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package authentication
+
+import (
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/identity"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+// Authentication identifies clients before requests are handled.
+type Authentication struct {
+	current atomic.Pointer[authenticators]
+	logger  logging.Logger
+}
+
+// authenticators configured for a single configuration, replaced as a whole when configuration is reloaded.
+type authenticators struct {
+	basicFallback bool
+	certificates  bool
+	oidc          *oidc
+	signer        *signer
+	webhook       *webhook
+}
+
+// New create a new Authentication instance.
+func New(config *configuration.Configuration, logger logging.Logger, server *echo.Echo, middleware ...echo.MiddlewareFunc) (*Authentication, error) {
+	auth := &Authentication{
+		logger: logger,
+	}
+
+	apply, err := auth.Prepare(config)
+	if err != nil {
+		return nil, err
+	}
+
+	apply()
+
+	if auth.Signing() {
+		server.POST("/sign/power/:action/:vm", auth.Presign, append(middleware, auth.Middleware)...)
+	}
+
+	return auth, nil
+}
+
+// Prepare create authenticators from a configuration and return a function which applies them.
+func (a *Authentication) Prepare(config *configuration.Configuration) (func(), error) {
+	next := &authenticators{
+		basicFallback: config.BasicFallback,
+		certificates:  config.TLSClientCAFile != "",
+	}
+
+	if config.WebhookSecret != "" {
+		next.webhook = &webhook{header: config.WebhookSignatureHeader, secret: []byte(config.WebhookSecret)}
+	}
+
+	if config.SigningSecret != "" {
+		next.signer = &signer{secret: []byte(config.SigningSecret)}
+	}
+
+	// Pre-signed url routes are only registered at startup
+	if current := a.current.Load(); current != nil && (current.signer == nil) != (next.signer == nil) {
+		return nil, errors.New("pre-signed urls can't be enabled or disabled without a restart")
+	}
+
+	if config.OIDCIssuer != "" {
+		var err error
+
+		next.oidc, err = newOIDC(config, a.logger)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to configure oidc authentication")
+		}
+	}
+
+	return func() {
+		a.current.Store(next)
+	}, nil
+}
+
+// Middleware identify the client making a request, requests which can't be identified are rejected.
+func (a *Authentication) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		current := a.current.Load()
+		scheme, credentials, _ := strings.Cut(strings.TrimSpace(ctx.Request().Header.Get("Authorization")), " ")
+
+		switch {
+		case current.signer != nil && ctx.QueryParam("signature") != "":
+			err := current.signer.verify(ctx.Request())
+			if err != nil {
+				a.logger.Warn(errors.Wrap(err, "rejected pre-signed url from %s", ctx.RealIP()))
+
+				return current.unauthorized(ctx, "invalid or expired signature")
+			}
+
+			identity.Set(ctx, identity.Identity{Method: "signature", Name: "pre-signed url"})
+		case current.webhook != nil && current.webhook.present(ctx.Request()):
+			err := current.webhook.verify(ctx.Response(), ctx.Request())
+			var httpError *echo.HTTPError
+			if errors.As(err, &httpError) {
+				return err
+			}
+
+			if err != nil {
+				a.logger.Warn(errors.Wrap(err, "rejected webhook signature from %s", ctx.RealIP()))
+
+				return current.unauthorized(ctx, "invalid webhook signature")
+			}
+
+			identity.Set(ctx, identity.Identity{Method: "webhook", Name: "webhook"})
+		case strings.EqualFold(scheme, "bearer") && current.oidc != nil:
+			client, err := current.oidc.authenticate(strings.TrimSpace(credentials))
+			if err != nil {
+				a.logger.Warn(errors.Wrap(err, "rejected bearer token from %s", ctx.RealIP()))
+
+				return current.unauthorized(ctx, "invalid bearer token")
+			}
+
+			identity.Set(ctx, client)
+
+			// The token is meaningless to vsphere, configured credentials are used instead
+			ctx.Request().Header.Del("Authorization")
+		case current.certificates && verified(ctx.Request()):
+			certificate := ctx.Request().TLS.VerifiedChains[0][0]
+
+			identity.Set(ctx, identity.Identity{
+				Method: "certificate",
+				Name:   certificate.Subject.CommonName,
+				Roles:  certificate.Subject.OrganizationalUnit,
+			})
+		case !current.enabled():
+			// Without any authenticators configured the authorization header is passed through to vsphere as is
+		case strings.EqualFold(scheme, "basic") && current.basicFallback:
+			// Credentials are verified by vsphere when they are passed through
+		default:
+			return current.unauthorized(ctx, "authentication required")
+		}
+
+		return next(ctx)
+	}
+}
+
+// Signing determine if pre-signed urls are enabled.
+func (a *Authentication) Signing() bool {
+	return a.current.Load().signer != nil
+}
+
+// enabled determine if any authenticators which reject unauthenticated requests are configured.
+func (a *authenticators) enabled() bool {
+	return a.certificates || a.oidc != nil || a.webhook != nil
+}
+
+// verified determine if a request was made with a client certificate which was verified during the tls handshake.
+func verified(request *http.Request) bool {
+	return request.TLS != nil && len(request.TLS.VerifiedChains) > 0 && len(request.TLS.VerifiedChains[0]) > 0
+}
+
+// unauthorized create an error advertising supported authentication schemes.
+func (a *authenticators) unauthorized(ctx echo.Context, message string) error {
+	challenges := make([]string, 0)
+	if a.oidc != nil {
+		challenges = append(challenges, "Bearer")
+	}
+
+	if a.basicFallback {
+		challenges = append(challenges, "Basic")
+	}
+
+	for _, challenge := range challenges {
+		ctx.Response().Header().Add(echo.HeaderWWWAuthenticate, challenge)
+	}
+
+	return echo.NewHTTPError(http.StatusUnauthorized, message)
+}
@@ -0,0 +1,240 @@
+package authentication
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+const (
+	// keySetRefreshInterval minimum time between refreshing keys when an unknown key is requested.
+	keySetRefreshInterval = 5 * time.Minute
+
+	// keySetTimeout timeout when fetching keys from a remote source.
+	keySetTimeout = 10 * time.Second
+)
+
+// jsonWebKey a single key within a json web key set.
+type jsonWebKey struct {
+	Crv string `json:"crv"`
+	E   string `json:"e"`
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	N   string `json:"n"`
+	Use string `json:"use"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// keySet public keys used to verify token signatures, loaded from a file or url.
+type keySet struct {
+	file      string
+	keys      map[string]crypto.PublicKey
+	logger    logging.Logger
+	mutex     sync.Mutex
+	refreshed time.Time
+	url       string
+}
+
+// key find a key by id, refreshing keys if the id is unknown.
+func (k *keySet) key(kid string) (crypto.PublicKey, error) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	key, ok := k.find(kid)
+	if ok {
+		return key, nil
+	}
+
+	// Keys may have been rotated, but don't hammer the provider with unknown key ids
+	if k.url == "" || time.Since(k.refreshed) < keySetRefreshInterval {
+		return nil, errors.New("unknown signing key %s", kid)
+	}
+
+	err := k.load()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to refresh jwks")
+	}
+
+	key, ok = k.find(kid)
+	if !ok {
+		return nil, errors.New("unknown signing key %s", kid)
+	}
+
+	return key, nil
+}
+
+// refresh reload keys from source.
+func (k *keySet) refresh() error {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	return k.load()
+}
+
+// find a key by id, if no id is provided the key is only found if there is a single key.
+func (k *keySet) find(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, true
+		}
+	}
+
+	key, ok := k.keys[kid]
+
+	return key, ok
+}
+
+// load keys from source, must be called while holding the mutex.
+func (k *keySet) load() error {
+	contents, err := k.read()
+	if err != nil {
+		return err
+	}
+
+	var document struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	err = json.Unmarshal(contents, &document)
+	if err != nil {
+		return errors.Wrap(err, "unable to unmarshal jwks")
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, webKey := range document.Keys {
+		// Only keys used for signatures are relevant
+		if webKey.Use != "" && webKey.Use != "sig" {
+			continue
+		}
+
+		// Providers often publish keys the bridge can't use, which shouldn't prevent the other keys being used
+		key, err := webKey.publicKey()
+		if err != nil {
+			k.logger.Debug("skipping jwks key %s: %v", webKey.Kid, err)
+
+			continue
+		}
+
+		keys[webKey.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return errors.New("jwks does not contain any usable signing keys")
+	}
+
+	k.keys = keys
+	k.refreshed = time.Now()
+
+	return nil
+}
+
+// read raw key set from file or url.
+func (k *keySet) read() ([]byte, error) {
+	if k.file != "" {
+		contents, err := os.ReadFile(k.file)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read jwks file %s", k.file)
+		}
+
+		return contents, nil
+	}
+
+	client := &http.Client{Timeout: keySetTimeout}
+
+	response, err := client.Get(k.url)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch jwks from %s", k.url)
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New("unexpected response received fetching jwks (%s)", response.Status)
+	}
+
+	var contents json.RawMessage
+	err = json.NewDecoder(response.Body).Decode(&contents)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decode jwks")
+	}
+
+	return contents, nil
+}
+
+// publicKey convert a json web key to a public key.
+func (j jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "EC":
+		var curve elliptic.Curve
+
+		switch j.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, errors.New("unsupported curve %s", j.Crv)
+		}
+
+		x, err := decodeInteger(j.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeInteger(j.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, errors.New("unsupported curve %s", j.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid ed25519 public key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	case "RSA":
+		n, err := decodeInteger(j.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeInteger(j.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	default:
+		return nil, errors.New("unsupported key type %s", j.Kty)
+	}
+}
+
+// decodeInteger decode a base64url encoded big endian integer.
+func decodeInteger(value string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid key parameter")
+	}
+
+	return new(big.Int).SetBytes(decoded), nil
+}
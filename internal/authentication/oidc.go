@@ -0,0 +1,178 @@
+package authentication
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/identity"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+// oidc validates bearer tokens issued by an OpenID Connect provider.
+type oidc struct {
+	audience    string
+	issuer      string
+	keys        *keySet
+	nameClaim   string
+	roleMapping map[string][]string
+	rolesClaim  string
+}
+
+// discovery OpenID Connect discovery document.
+type discovery struct {
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// leeway allowed clock skew when validating token times.
+const leeway = 30 * time.Second
+
+// newOIDC create a new oidc instance.
+func newOIDC(config *configuration.Configuration, logger logging.Logger) (*oidc, error) {
+	if config.OIDCAudience == "" {
+		return nil, errors.New("oidc audience is required when an oidc issuer is set")
+	}
+
+	keys := &keySet{file: config.OIDCJWKSFile, logger: logger, url: config.OIDCJWKSURL}
+	if keys.file == "" && keys.url == "" {
+		jwksURL, err := discover(config.OIDCIssuer)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to discover jwks url")
+		}
+
+		keys.url = jwksURL
+	}
+
+	err := keys.refresh()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load jwks")
+	}
+
+	roleMapping := make(map[string][]string)
+	for _, mapping := range strings.Split(config.OIDCRoleMapping, ",") {
+		value, role, found := strings.Cut(mapping, "=")
+		if !found {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		roleMapping[value] = append(roleMapping[value], strings.TrimSpace(role))
+	}
+
+	return &oidc{
+		audience:    config.OIDCAudience,
+		issuer:      config.OIDCIssuer,
+		keys:        keys,
+		nameClaim:   config.OIDCNameClaim,
+		roleMapping: roleMapping,
+		rolesClaim:  config.OIDCRolesClaim,
+	}, nil
+}
+
+// authenticate validate a token and resolve the identity it represents.
+func (o *oidc) authenticate(token string) (identity.Identity, error) {
+	claims := jwt.MapClaims{}
+
+	_, err := jwt.ParseWithClaims(
+		token,
+		claims,
+		func(token *jwt.Token) (any, error) {
+			kid, _ := token.Header["kid"].(string)
+
+			return o.keys.key(kid)
+		},
+		jwt.WithAudience(o.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuer(o.issuer),
+		jwt.WithLeeway(leeway),
+		jwt.WithValidMethods([]string{"ES256", "ES384", "ES512", "EdDSA", "PS256", "PS384", "PS512", "RS256", "RS384", "RS512"}),
+	)
+	if err != nil {
+		return identity.Identity{}, errors.Wrap(err, "invalid token")
+	}
+
+	name, _ := claims[o.nameClaim].(string)
+	if name == "" {
+		name, _ = claims["sub"].(string)
+	}
+
+	if name == "" {
+		return identity.Identity{}, errors.New("token does not identify a subject")
+	}
+
+	return identity.Identity{
+		Method: "oidc",
+		Name:   name,
+		Roles:  o.roles(claims),
+	}, nil
+}
+
+// roles resolve roles from the configured claim, mapping claim values to roles if a mapping is configured.
+func (o *oidc) roles(claims jwt.MapClaims) []string {
+	var claim any = map[string]any(claims)
+
+	// Nested claims such as realm_access.roles are separated by periods
+	for _, segment := range strings.Split(o.rolesClaim, ".") {
+		object, ok := claim.(map[string]any)
+		if !ok {
+			return []string{}
+		}
+
+		claim = object[segment]
+	}
+
+	values := make([]string, 0)
+	switch claimType := claim.(type) {
+	case string:
+		values = strings.Fields(claimType)
+	case []any:
+		for _, value := range claimType {
+			if text, ok := value.(string); ok {
+				values = append(values, text)
+			}
+		}
+	}
+
+	if len(o.roleMapping) == 0 {
+		return values
+	}
+
+	roles := make([]string, 0)
+	for _, value := range values {
+		roles = append(roles, o.roleMapping[value]...)
+	}
+
+	return roles
+}
+
+// discover find the jwks url from the issuer discovery document.
+func discover(issuer string) (string, error) {
+	client := &http.Client{Timeout: keySetTimeout}
+
+	response, err := client.Get(strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return "", errors.Wrap(err, "unable to fetch discovery document")
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode != http.StatusOK {
+		return "", errors.New("unexpected response received from issuer (%s)", response.Status)
+	}
+
+	var document discovery
+	err = json.NewDecoder(response.Body).Decode(&document)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to decode discovery document")
+	}
+
+	if document.JWKSURI == "" {
+		return "", errors.New("discovery document does not contain a jwks_uri")
+	}
+
+	return document.JWKSURI, nil
+}
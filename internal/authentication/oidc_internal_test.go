@@ -0,0 +1,362 @@
+package authentication
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+// testKeys locally generated keys used to sign tokens.
+type testKeys struct {
+	ec    *ecdsa.PrivateKey
+	other *rsa.PrivateKey
+	rsa   *rsa.PrivateKey
+}
+
+// encode base64url encode a big endian integer, padded to a size in bytes.
+func encode(value *big.Int, size int) string {
+	return base64.RawURLEncoding.EncodeToString(value.FillBytes(make([]byte, size)))
+}
+
+// newTestKeys generate keys and a jwks document which also contains keys the bridge can't use.
+func newTestKeys(t *testing.T) (testKeys, []byte) {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	document, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "oct", "kid": "symmetric", "k": "c2VjcmV0"},
+		{"kty": "EC", "kid": "secp256k1", "crv": "secp256k1", "x": "AA", "y": "AA"},
+		{"kty": "RSA", "kid": "encryption", "use": "enc", "n": encode(otherKey.N, 256), "e": "AQAB"},
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encode(rsaKey.N, 256), "e": "AQAB"},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encode(ecKey.X, 32), "y": encode(ecKey.Y, 32)},
+	}})
+	require.NoError(t, err)
+
+	return testKeys{ec: ecKey, other: otherKey, rsa: rsaKey}, document
+}
+
+// newTestIssuer serve a discovery document and jwks.
+func newTestIssuer(t *testing.T, document []byte) string {
+	t.Helper()
+
+	var issuer string
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(writer).Encode(map[string]string{"jwks_uri": issuer + "/jwks"})
+		case "/jwks":
+			_, _ = writer.Write(document)
+		default:
+			writer.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	issuer = server.URL
+
+	return issuer
+}
+
+// newTestLogger create a logger which discards messages.
+func newTestLogger(t *testing.T) logging.Logger {
+	t.Helper()
+
+	logger, err := logging.New(logging.Error, io.Discard, 0)
+	require.NoError(t, err)
+
+	return logger
+}
+
+func TestOIDC_Authenticate(t *testing.T) {
+	t.Parallel()
+
+	keys, document := newTestKeys(t)
+	issuer := newTestIssuer(t, document)
+
+	authenticator, err := newOIDC(&configuration.Configuration{
+		OIDCAudience:   "bridge",
+		OIDCIssuer:     issuer,
+		OIDCNameClaim:  "preferred_username",
+		OIDCRolesClaim: "realm_access.roles",
+	}, newTestLogger(t))
+	require.NoError(t, err)
+
+	claims := func(changes jwt.MapClaims) jwt.MapClaims {
+		base := jwt.MapClaims{
+			"aud":                "bridge",
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"iss":                issuer,
+			"preferred_username": "alice",
+			"realm_access":       map[string]any{"roles": []string{"operators"}},
+			"sub":                "1234",
+		}
+
+		for key, value := range changes {
+			if value == nil {
+				delete(base, key)
+
+				continue
+			}
+
+			base[key] = value
+		}
+
+		return base
+	}
+
+	sign := func(method jwt.SigningMethod, kid string, key any, tokenClaims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(method, tokenClaims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+
+		return signed
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&keys.rsa.PublicKey)
+	require.NoError(t, err)
+
+	testcases := map[string]struct {
+		token string
+		name  string
+		roles []string
+		err   bool
+	}{
+		"rsa": {
+			token: sign(jwt.SigningMethodRS256, "rsa", keys.rsa, claims(nil)),
+			name:  "alice",
+			roles: []string{"operators"},
+		},
+		"ecdsa": {
+			token: sign(jwt.SigningMethodES256, "ec", keys.ec, claims(nil)),
+			name:  "alice",
+			roles: []string{"operators"},
+		},
+		"subject when name claim is missing": {
+			token: sign(jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"preferred_username": nil})),
+			name:  "1234",
+			roles: []string{"operators"},
+		},
+		"audience list": {
+			token: sign(jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"aud": []string{"other", "bridge"}})),
+			name:  "alice",
+			roles: []string{"operators"},
+		},
+		"wrong issuer": {
+			token: sign(jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"iss": "https://elsewhere"})),
+			err:   true,
+		},
+		"wrong audience": {
+			token: sign(jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"aud": "other"})),
+			err:   true,
+		},
+		"expired": {
+			token: sign(jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})),
+			err:   true,
+		},
+		"expired within leeway": {
+			token: sign(jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"exp": time.Now().Add(-10 * time.Second).Unix()})),
+			name:  "alice",
+			roles: []string{"operators"},
+		},
+		"missing expiry": {
+			token: sign(jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"exp": nil})),
+			err:   true,
+		},
+		"hmac signed with public key": {
+			token: sign(jwt.SigningMethodHS256, "rsa", publicKey, claims(nil)),
+			err:   true,
+		},
+		"unsigned": {
+			token: sign(jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType, claims(nil)),
+			err:   true,
+		},
+		"algorithm doesn't match key": {
+			token: sign(jwt.SigningMethodES256, "rsa", keys.ec, claims(nil)),
+			err:   true,
+		},
+		"unknown kid": {
+			token: sign(jwt.SigningMethodRS256, "missing", keys.rsa, claims(nil)),
+			err:   true,
+		},
+		"skipped kid": {
+			token: sign(jwt.SigningMethodRS256, "encryption", keys.other, claims(nil)),
+			err:   true,
+		},
+		"signed by another key": {
+			token: sign(jwt.SigningMethodRS256, "rsa", keys.other, claims(nil)),
+			err:   true,
+		},
+		"no subject": {
+			token: sign(jwt.SigningMethodRS256, "rsa", keys.rsa, claims(jwt.MapClaims{"preferred_username": nil, "sub": nil})),
+			err:   true,
+		},
+		"malformed": {
+			token: "not.a.token",
+			err:   true,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			client, err := authenticator.authenticate(testcase.token)
+			if testcase.err {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "oidc", client.Method)
+			assert.Equal(t, testcase.name, client.Name)
+			assert.Equal(t, testcase.roles, client.Roles)
+		})
+	}
+}
+
+func TestOIDC_Roles(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		claim    string
+		mapping  map[string][]string
+		claims   jwt.MapClaims
+		expected []string
+	}{
+		"list": {
+			claim:    "groups",
+			claims:   jwt.MapClaims{"groups": []any{"operators", "admins"}},
+			expected: []string{"operators", "admins"},
+		},
+		"space separated": {
+			claim:    "scope",
+			claims:   jwt.MapClaims{"scope": "power:read power:write"},
+			expected: []string{"power:read", "power:write"},
+		},
+		"nested": {
+			claim:    "realm_access.roles",
+			claims:   jwt.MapClaims{"realm_access": map[string]any{"roles": []any{"oncall"}}},
+			expected: []string{"oncall"},
+		},
+		"missing": {
+			claim:    "realm_access.roles",
+			claims:   jwt.MapClaims{"groups": []any{"operators"}},
+			expected: []string{},
+		},
+		"not an object": {
+			claim:    "realm_access.roles",
+			claims:   jwt.MapClaims{"realm_access": "roles"},
+			expected: []string{},
+		},
+		"non string values": {
+			claim:    "groups",
+			claims:   jwt.MapClaims{"groups": []any{"operators", 1, true}},
+			expected: []string{"operators"},
+		},
+		"mapped": {
+			claim:    "groups",
+			mapping:  map[string][]string{"vsphere-operators": {"oncall"}, "vsphere-admins": {"admin", "oncall"}},
+			claims:   jwt.MapClaims{"groups": []any{"vsphere-admins", "unmapped"}},
+			expected: []string{"admin", "oncall"},
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			authenticator := &oidc{roleMapping: testcase.mapping, rolesClaim: testcase.claim}
+
+			assert.Equal(t, testcase.expected, authenticator.roles(testcase.claims))
+		})
+	}
+}
+
+func TestKeySet_Load(t *testing.T) {
+	t.Parallel()
+
+	_, document := newTestKeys(t)
+
+	testcases := map[string]struct {
+		document string
+		expected []string
+		err      bool
+	}{
+		"unusable keys are skipped": {
+			document: string(document),
+			expected: []string{"ec", "rsa"},
+		},
+		"only unusable keys": {
+			document: `{"keys":[{"kty":"oct","kid":"symmetric","k":"c2VjcmV0"},{"kty":"OKP","kid":"x","crv":"X25519","x":"AA"}]}`,
+			err:      true,
+		},
+		"no keys": {
+			document: `{"keys":[]}`,
+			err:      true,
+		},
+		"invalid json": {
+			document: `{"keys":`,
+			err:      true,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "jwks.json")
+			require.NoError(t, os.WriteFile(path, []byte(testcase.document), 0o600))
+
+			keys := &keySet{file: path, logger: newTestLogger(t)}
+
+			err := keys.refresh()
+			if testcase.err {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			kids := make([]string, 0, len(keys.keys))
+			for kid := range keys.keys {
+				kids = append(kids, kid)
+			}
+
+			assert.ElementsMatch(t, testcase.expected, kids)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package authentication
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sjdaws/vsphere-bridge/internal/identity"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+const (
+	// defaultSignatureTTL time a pre-signed url is valid for if no ttl is requested.
+	defaultSignatureTTL = time.Hour
+
+	// maxSignatureTTL maximum time a pre-signed url can be valid for.
+	maxSignatureTTL = 7 * 24 * time.Hour
+)
+
+// signer creates and verifies pre-signed urls.
+type signer struct {
+	secret []byte
+}
+
+// Presign create a pre-signed url which performs an action against a virtual machine using a GET request.
+func (a *Authentication) Presign(ctx echo.Context) error {
+	// Basic credentials are only verified by vsphere once they are passed through, so they can't create urls
+	switch identity.FromContext(ctx).Method {
+	case "certificate", "oidc", "webhook":
+	default:
+		return a.current.Load().unauthorized(ctx, "pre-signed urls can only be created by oidc, client certificate or webhook authenticated clients")
+	}
+
+	action := ctx.Param("action")
+
+	switch action {
+	case "cycle", "off", "on", "reset", "suspend":
+	default:
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("unsupported action %s", action))
+	}
+
+	ttl := defaultSignatureTTL
+	if ctx.QueryParam("ttl") != "" {
+		var err error
+
+		ttl, err = time.ParseDuration(ctx.QueryParam("ttl"))
+		if err != nil || ttl <= 0 || ttl > maxSignatureTTL {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("ttl must be a duration between 0s and %s", maxSignatureTTL))
+		}
+	}
+
+	path := fmt.Sprintf("/power/%s/%s", action, ctx.Param("vm"))
+	expires := time.Now().Add(ttl).Unix()
+
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires, 10)},
+		"signature": {a.current.Load().signer.sign(http.MethodGet, path, expires)},
+	}
+
+	signed := url.URL{
+		Host:     ctx.Request().Host,
+		Path:     path,
+		RawQuery: query.Encode(),
+		Scheme:   ctx.Scheme(),
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]any{"result": "ok", "expires": time.Unix(expires, 0).UTC(), "url": signed.String()})
+}
+
+// RequireSignature middleware which only allows requests made using a valid pre-signed url.
+func (a *Authentication) RequireSignature(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		err := a.current.Load().signer.verify(ctx.Request())
+		if err != nil {
+			a.logger.Warn(errors.Wrap(err, "rejected pre-signed url from %s", ctx.RealIP()))
+
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid or expired signature")
+		}
+
+		return next(ctx)
+	}
+}
+
+// sign a method and path which expires at a point in time.
+func (s *signer) sign(method string, path string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(fmt.Sprintf("%s\n%s\n%d", method, path, expires)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verify the signature and expiry sent in the query string of a request.
+func (s *signer) verify(request *http.Request) error {
+	query := request.URL.Query()
+
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "invalid expiry")
+	}
+
+	if time.Now().Unix() > expires {
+		return errors.New("signature expired at %s", time.Unix(expires, 0).UTC())
+	}
+
+	expected, err := hex.DecodeString(query.Get("signature"))
+	if err != nil {
+		return errors.Wrap(err, "signature is not hex encoded")
+	}
+
+	actual, _ := hex.DecodeString(s.sign(request.Method, request.URL.Path, expires))
+	if !hmac.Equal(actual, expected) {
+		return errors.New("signature does not match request")
+	}
+
+	return nil
+}
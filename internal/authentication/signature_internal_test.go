@@ -0,0 +1,217 @@
+package authentication
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/internal/identity"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+// newTestAuthentication create an Authentication instance which signs urls with a secret.
+func newTestAuthentication(t *testing.T) *Authentication {
+	t.Helper()
+
+	logger, err := logging.New(logging.Error, io.Discard, 0)
+	require.NoError(t, err)
+
+	auth := &Authentication{logger: logger}
+	auth.current.Store(&authenticators{signer: &signer{secret: []byte("secret")}})
+
+	return auth
+}
+
+// statusOf get the status an error returned by a handler would be sent with.
+func statusOf(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+
+	var httpError *echo.HTTPError
+	if errors.As(err, &httpError) {
+		return httpError.Code
+	}
+
+	return http.StatusInternalServerError
+}
+
+func TestAuthentication_Presign(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		action   string
+		client   identity.Identity
+		ttl      string
+		expected int
+	}{
+		"oidc client": {
+			action:   "on",
+			client:   identity.Identity{Method: "oidc", Name: "ci"},
+			expected: http.StatusOK,
+		},
+		"certificate client": {
+			action:   "reset",
+			client:   identity.Identity{Method: "certificate", Name: "alertmanager"},
+			ttl:      "30m",
+			expected: http.StatusOK,
+		},
+		"webhook client": {
+			action:   "off",
+			client:   identity.Identity{Method: "webhook", Name: "webhook"},
+			expected: http.StatusOK,
+		},
+		"basic client": {
+			action:   "on",
+			client:   identity.Identity{Method: "basic", Name: "someone"},
+			expected: http.StatusUnauthorized,
+		},
+		"pre-signed url": {
+			action:   "on",
+			client:   identity.Identity{Method: "signature", Name: "pre-signed url"},
+			expected: http.StatusUnauthorized,
+		},
+		"anonymous": {
+			action:   "on",
+			client:   identity.Anonymous,
+			expected: http.StatusUnauthorized,
+		},
+		"unsupported action": {
+			action:   "destroy",
+			client:   identity.Identity{Method: "oidc", Name: "ci"},
+			expected: http.StatusBadRequest,
+		},
+		"ttl too long": {
+			action:   "on",
+			client:   identity.Identity{Method: "oidc", Name: "ci"},
+			ttl:      "720h",
+			expected: http.StatusBadRequest,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			auth := newTestAuthentication(t)
+
+			target := "/sign/power/" + testcase.action + "/vm1"
+			if testcase.ttl != "" {
+				target += "?ttl=" + testcase.ttl
+			}
+
+			recorder := httptest.NewRecorder()
+			ctx := echo.New().NewContext(httptest.NewRequest(http.MethodPost, target, nil), recorder)
+			ctx.SetParamNames("action", "vm")
+			ctx.SetParamValues(testcase.action, "vm1")
+			identity.Set(ctx, testcase.client)
+
+			err := auth.Presign(ctx)
+			require.Equal(t, testcase.expected, statusOf(err))
+
+			if err != nil {
+				return
+			}
+
+			var response struct {
+				URL string `json:"url"`
+			}
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+			signed, err := url.Parse(response.URL)
+			require.NoError(t, err)
+			assert.Equal(t, "/power/"+testcase.action+"/vm1", signed.Path)
+
+			// The url is accepted for the action it was created for
+			request := httptest.NewRequest(http.MethodGet, signed.RequestURI(), nil)
+			require.NoError(t, auth.current.Load().signer.verify(request))
+		})
+	}
+}
+
+func TestAuthentication_RequireSignature(t *testing.T) {
+	t.Parallel()
+
+	valid := time.Now().Add(time.Hour).Unix()
+	expired := time.Now().Add(-time.Minute).Unix()
+	sign := &signer{secret: []byte("secret")}
+
+	query := func(method string, path string, expires int64) string {
+		return url.Values{
+			"expires":   {strconv.FormatInt(expires, 10)},
+			"signature": {sign.sign(method, path, expires)},
+		}.Encode()
+	}
+
+	testcases := map[string]struct {
+		method   string
+		target   string
+		expected int
+	}{
+		"valid": {
+			method:   http.MethodGet,
+			target:   "/power/on/vm1?" + query(http.MethodGet, "/power/on/vm1", valid),
+			expected: http.StatusOK,
+		},
+		"expired": {
+			method:   http.MethodGet,
+			target:   "/power/on/vm1?" + query(http.MethodGet, "/power/on/vm1", expired),
+			expected: http.StatusUnauthorized,
+		},
+		"changed expiry": {
+			method:   http.MethodGet,
+			target:   "/power/on/vm1?expires=" + strconv.FormatInt(valid+3600, 10) + "&signature=" + sign.sign(http.MethodGet, "/power/on/vm1", valid),
+			expected: http.StatusUnauthorized,
+		},
+		"changed path": {
+			method:   http.MethodGet,
+			target:   "/power/off/vm1?" + query(http.MethodGet, "/power/on/vm1", valid),
+			expected: http.StatusUnauthorized,
+		},
+		"changed vm": {
+			method:   http.MethodGet,
+			target:   "/power/on/vm2?" + query(http.MethodGet, "/power/on/vm1", valid),
+			expected: http.StatusUnauthorized,
+		},
+		"changed method": {
+			method:   http.MethodPost,
+			target:   "/power/on/vm1?" + query(http.MethodGet, "/power/on/vm1", valid),
+			expected: http.StatusUnauthorized,
+		},
+		"wrong secret": {
+			method:   http.MethodGet,
+			target:   "/power/on/vm1?expires=" + strconv.FormatInt(valid, 10) + "&signature=" + (&signer{secret: []byte("other")}).sign(http.MethodGet, "/power/on/vm1", valid),
+			expected: http.StatusUnauthorized,
+		},
+		"missing signature": {
+			method:   http.MethodGet,
+			target:   "/power/on/vm1?expires=" + strconv.FormatInt(valid, 10),
+			expected: http.StatusUnauthorized,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			auth := newTestAuthentication(t)
+			handler := auth.RequireSignature(func(ctx echo.Context) error {
+				return ctx.NoContent(http.StatusOK)
+			})
+
+			ctx := echo.New().NewContext(httptest.NewRequest(testcase.method, testcase.target, nil), httptest.NewRecorder())
+
+			assert.Equal(t, testcase.expected, statusOf(handler(ctx)))
+		})
+	}
+}
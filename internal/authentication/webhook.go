@@ -0,0 +1,114 @@
+package authentication
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+const (
+	// hubSignatureHeader header used by GitHub and Gitea to send a signature of the request body.
+	hubSignatureHeader = "X-Hub-Signature-256"
+
+	// maxWebhookBody maximum size of a request body which will be read to verify a signature.
+	maxWebhookBody = 1 << 20
+
+	// maxWebhookSkew maximum difference between the signature timestamp and the current time.
+	maxWebhookSkew = 5 * time.Minute
+
+	// timestampHeader header containing the time a webhook was signed as a unix timestamp.
+	timestampHeader = "X-Signature-Timestamp"
+)
+
+// webhook verifies HMAC signatures sent by webhook senders.
+type webhook struct {
+	header string
+	secret []byte
+}
+
+// signature find the signature sent with a request, if any, and whether it was sent in the hub signature header.
+func (w *webhook) signature(request *http.Request) (string, bool) {
+	signature := strings.TrimSpace(request.Header.Get(hubSignatureHeader))
+	if signature != "" {
+		return signature, true
+	}
+
+	return strings.TrimSpace(request.Header.Get(w.header)), false
+}
+
+// present determine if a request was sent with a signature.
+func (w *webhook) present(request *http.Request) bool {
+	signature, _ := w.signature(request)
+
+	return signature != ""
+}
+
+// verify the signature sent with a request. Hub signatures cover the request body as GitHub and Gitea can't sign
+// anything else, signatures sent in the configured header also cover the method, path and a recent timestamp so
+// captured requests can't be replayed against other routes or later on.
+func (w *webhook) verify(writer http.ResponseWriter, request *http.Request) error {
+	signature, hub := w.signature(request)
+
+	var prefix string
+	if !hub {
+		timestamp, err := strconv.ParseInt(strings.TrimSpace(request.Header.Get(timestampHeader)), 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "invalid signature timestamp")
+		}
+
+		signed := time.Unix(timestamp, 0)
+		if skew := time.Since(signed).Abs(); skew > maxWebhookSkew {
+			return errors.New("signature timestamp %s is more than %s from the current time", signed.UTC(), maxWebhookSkew)
+		}
+
+		prefix = fmt.Sprintf("%s\n%s\n%d\n", request.Method, request.URL.Path, timestamp)
+	}
+
+	// Signatures may be prefixed with the algorithm, only sha256 is supported
+	algorithm, digest, found := strings.Cut(signature, "=")
+	if !found {
+		digest = algorithm
+	} else if !strings.EqualFold(algorithm, "sha256") {
+		return errors.New("unsupported signature algorithm %s", algorithm)
+	}
+
+	expected, err := hex.DecodeString(digest)
+	if err != nil {
+		return errors.Wrap(err, "signature is not hex encoded")
+	}
+
+	// A truncated body would be verified and handled as if it were the whole body
+	body, err := io.ReadAll(http.MaxBytesReader(writer, request.Body, maxWebhookBody))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, "request body is too large to verify")
+		}
+
+		return errors.Wrap(err, "unable to read request body")
+	}
+
+	// Restore body so it can be read by handlers
+	request.Body = io.NopCloser(bytes.NewReader(body))
+
+	mac := hmac.New(sha256.New, w.secret)
+	mac.Write([]byte(prefix))
+	mac.Write(body)
+
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return errors.New("signature does not match request")
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package authentication
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+// hmacHex create a hex encoded HMAC-SHA256 of a message.
+func hmacHex(secret string, message string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(message))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhook_Verify(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now().Unix()
+	stale := time.Now().Add(-10 * time.Minute).Unix()
+	body := `{"alert":"down"}`
+
+	signed := func(method string, path string, timestamp int64, content string) string {
+		return hmacHex("secret", fmt.Sprintf("%s\n%s\n%d\n%s", method, path, timestamp, content))
+	}
+
+	testcases := map[string]struct {
+		body      string
+		headers   map[string]string
+		path      string
+		status    int
+		err       bool
+		present   bool
+		timestamp int64
+	}{
+		"valid hub signature": {
+			body:    body,
+			headers: map[string]string{hubSignatureHeader: "sha256=" + hmacHex("secret", body)},
+			present: true,
+		},
+		"valid signature": {
+			body:    body,
+			headers: map[string]string{"X-Signature": signed(http.MethodPost, "/power/on/vm1", now, body)},
+			present: true,
+		},
+		"tampered body": {
+			body:    `{"alert":"up"}`,
+			headers: map[string]string{hubSignatureHeader: "sha256=" + hmacHex("secret", body)},
+			present: true,
+			err:     true,
+		},
+		"wrong secret": {
+			body:    body,
+			headers: map[string]string{hubSignatureHeader: "sha256=" + hmacHex("other", body)},
+			present: true,
+			err:     true,
+		},
+		"missing header": {
+			body: body,
+			err:  true,
+		},
+		"unsupported algorithm": {
+			body:    body,
+			headers: map[string]string{hubSignatureHeader: "sha1=" + hmacHex("secret", body)},
+			present: true,
+			err:     true,
+		},
+		"missing timestamp": {
+			body:      body,
+			headers:   map[string]string{"X-Signature": signed(http.MethodPost, "/power/on/vm1", now, body)},
+			present:   true,
+			err:       true,
+			timestamp: -1,
+		},
+		"stale timestamp": {
+			body:      body,
+			headers:   map[string]string{"X-Signature": signed(http.MethodPost, "/power/on/vm1", stale, body)},
+			present:   true,
+			err:       true,
+			timestamp: stale,
+		},
+		"changed path": {
+			body:    body,
+			headers: map[string]string{"X-Signature": signed(http.MethodPost, "/power/on/vm1", now, body)},
+			path:    "/power/off/vm1",
+			present: true,
+			err:     true,
+		},
+		"body too large": {
+			body:    strings.Repeat("a", maxWebhookBody+1),
+			headers: map[string]string{hubSignatureHeader: "sha256=" + hmacHex("secret", "a")},
+			present: true,
+			err:     true,
+			status:  http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := testcase.path
+			if path == "" {
+				path = "/power/on/vm1"
+			}
+
+			request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(testcase.body))
+			for key, value := range testcase.headers {
+				request.Header.Set(key, value)
+			}
+
+			switch testcase.timestamp {
+			case 0:
+				request.Header.Set(timestampHeader, strconv.FormatInt(now, 10))
+			case -1:
+			default:
+				request.Header.Set(timestampHeader, strconv.FormatInt(testcase.timestamp, 10))
+			}
+
+			hook := &webhook{header: "X-Signature", secret: []byte("secret")}
+			assert.Equal(t, testcase.present, hook.present(request))
+
+			err := hook.verify(httptest.NewRecorder(), request)
+			if testcase.err {
+				require.Error(t, err)
+
+				if testcase.status != 0 {
+					var httpError *echo.HTTPError
+					require.True(t, errors.As(err, &httpError))
+					assert.Equal(t, testcase.status, httpError.Code)
+				}
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			// Handlers can still read the body once it has been verified
+			restored, err := io.ReadAll(request.Body)
+			require.NoError(t, err)
+			assert.Equal(t, testcase.body, string(restored))
+		})
+	}
+}
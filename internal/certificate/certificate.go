@@ -0,0 +1,171 @@
+package certificate
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+// checkInterval minimum time between checking certificate files for changes.
+const checkInterval = 10 * time.Second
+
+// versions supported minimum tls versions.
+var versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Store serves a certificate and client certificate authorities which are reloaded when their files change.
+type Store struct {
+	caFile      string
+	certFile    string
+	certificate *tls.Certificate
+	checked     time.Time
+	clientAuth  tls.ClientAuthType
+	clientCAs   *x509.CertPool
+	keyFile     string
+	logger      logging.Logger
+	minVersion  uint16
+	modified    map[string]time.Time
+	mutex       sync.Mutex
+}
+
+// New load the certificate, key and optional client certificate authorities set by configuration.
+func New(config *configuration.Configuration, logger logging.Logger) (*Store, error) {
+	version, ok := versions[config.TLSMinVersion]
+	if !ok {
+		return nil, errors.New("unsupported minimum tls version %s, expected one of: 1.0, 1.1, 1.2, 1.3", config.TLSMinVersion)
+	}
+
+	store := &Store{
+		caFile:     config.TLSClientCAFile,
+		certFile:   config.TLSCertFile,
+		clientAuth: tls.NoClientCert,
+		keyFile:    config.TLSKeyFile,
+		logger:     logger,
+		minVersion: version,
+	}
+
+	// Client certificates are verified against the configured authorities when they are presented
+	if config.TLSClientCAFile != "" {
+		store.clientAuth = tls.VerifyClientCertIfGiven
+		if config.TLSClientCertRequired {
+			store.clientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	err := store.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Config create a tls configuration which uses the current certificate for each connection.
+func (s *Store) Config() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(_ *tls.ClientHelloInfo) (*tls.Config, error) {
+			certificate, clientCAs := s.current()
+
+			return &tls.Config{
+				Certificates: []tls.Certificate{*certificate},
+				ClientAuth:   s.clientAuth,
+				ClientCAs:    clientCAs,
+				MinVersion:   s.minVersion,
+			}, nil
+		},
+		MinVersion: s.minVersion,
+	}
+}
+
+// current certificate and client certificate authorities, reloading them if their files have changed.
+func (s *Store) current() (*tls.Certificate, *x509.CertPool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if time.Since(s.checked) >= checkInterval {
+		s.checked = time.Now()
+
+		if s.changed() {
+			err := s.reload()
+			if err != nil {
+				// Keep serving the previous certificate, the files may be part way through being replaced
+				s.logger.Error(errors.Wrap(err, "unable to reload tls certificate"))
+			} else {
+				s.logger.Info("tls certificate reloaded from %s", s.certFile)
+			}
+		}
+	}
+
+	return s.certificate, s.clientCAs
+}
+
+// changed determine if any file has been modified since it was loaded, must be called while holding the mutex.
+func (s *Store) changed() bool {
+	for path, last := range s.modified {
+		info, err := os.Stat(path)
+		if err == nil && !info.ModTime().Equal(last) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// load files while holding the mutex.
+func (s *Store) load() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.reload()
+}
+
+// reload files, must be called while holding the mutex.
+func (s *Store) reload() error {
+	modified := make(map[string]time.Time)
+	for _, path := range []string{s.certFile, s.keyFile, s.caFile} {
+		if path == "" {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return errors.Wrap(err, "unable to read %s", path)
+		}
+
+		modified[path] = info.ModTime()
+	}
+
+	certificate, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to load certificate %s and key %s", s.certFile, s.keyFile)
+	}
+
+	var clientCAs *x509.CertPool
+	if s.caFile != "" {
+		contents, err := os.ReadFile(s.caFile)
+		if err != nil {
+			return errors.Wrap(err, "unable to read client certificate authorities %s", s.caFile)
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(contents) {
+			return errors.New("no certificates found in %s", s.caFile)
+		}
+	}
+
+	s.certificate = &certificate
+	s.clientCAs = clientCAs
+	s.modified = modified
+
+	return nil
+}
@@ -2,85 +2,643 @@ package configuration
 
 import (
 	"flag"
+	"io"
 	"net/url"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"filippo.io/age"
 	"github.com/carlmjohnson/truthy"
 
+	"github.com/sjdaws/vsphere-bridge/internal/secrets"
 	"github.com/sjdaws/vsphere-bridge/pkg/errors"
 )
 
-// Configuration resolved configuration from os.Getenv and os.Args.
+// Configuration resolved configuration from defaults, a configuration file, os.Getenv and os.Args.
 type Configuration struct {
-	Insecure  bool
-	NotifyURL string
-	Password  string
-	Port      string
-	Server    *url.URL
-	Username  string
-	fqdn      string
+	AdminAllow                string
+	AdminBindAddress          string
+	AdminDeny                 string
+	AdminPort                 string
+	APIPassthrough            string
+	BasicFallback             bool
+	BindAddress               string
+	BreakerCooldown           time.Duration
+	BreakerThreshold          int
+	ConfigFile                string
+	ConfigReloadInterval      time.Duration
+	CredentialsFile           string
+	CredentialsKey            string
+	CredentialsKeyFile        string
+	CredentialsReloadInterval time.Duration
+	Debug                     bool
+	HealthAllow               string
+	HealthCheckInterval       time.Duration
+	HealthDeny                string
+	Insecure                  bool
+	LockoutDuration           time.Duration
+	LockoutMaxDuration        time.Duration
+	LockoutThreshold          int
+	NotifyURL                 string
+	OIDCAudience              string
+	OIDCIssuer                string
+	OIDCJWKSFile              string
+	OIDCJWKSURL               string
+	OIDCNameClaim             string
+	OIDCRoleMapping           string
+	OIDCRolesClaim            string
+	Password                  string
+	PasswordFile              string
+	PolicyFile                string
+	Port                      string
+	PowerAllow                string
+	PowerDeny                 string
+	PowerLimits               string
+	PowerOnConcurrency        int
+	PowerOnPriorities         string
+	PowerOnStagger            time.Duration
+	QuotaFile                 string
+	RetryAttempts             int
+	RetryBackoff              time.Duration
+	RetryTimeout              time.Duration
+	Server                    *url.URL
+	ShutdownTimeout           time.Duration
+	SigningSecret             string
+	TLSCertFile               string
+	TLSClientCAFile           string
+	TLSClientCertRequired     bool
+	TLSKeyFile                string
+	TLSMinVersion             string
+	TrustedProxies            string
+	UnixSocket                string
+	UnixSocketTrusted         bool
+	Username                  string
+	UsernameFile              string
+	VsphereMaxConcurrency     int
+	VsphereProxy              string
+	VsphereRateBurst          int
+	VsphereRateLimit          int
+	WebhookSecret             string
+	WebhookSignatureHeader    string
+	fqdn                      string
 }
 
+// resolved configuration values keyed by name.
 type resolved map[string]string
 
-// Resolve configuration from environment and command arguments.
+// Setting effective value of a configuration key and the source which set it.
+type Setting struct {
+	Key    string `json:"key"`
+	Source string `json:"source"`
+	Value  string `json:"value"`
+}
+
+// source configuration values from a single origin.
+type source struct {
+	decrypted map[string]bool
+	name      string
+	values    resolved
+}
+
+// sources configuration values ordered from highest to lowest precedence.
+type sources []source
+
+// redacted placeholder shown instead of secret values.
+const redacted = "********"
+
+// sensitive keys which are never shown.
+var sensitive = map[string]bool{
+	"credentials_key": true,
+	"notify_url":      true,
+	"password":        true,
+	"secrets_key":     true,
+	"signing_secret":  true,
+	"vsphere_proxy":   true,
+	"webhook_secret":  true,
+}
+
+// booleans keys which are boolean values.
+var booleans = map[string]bool{
+	"basic_fallback":           true,
+	"debug":                    true,
+	"insecure":                 true,
+	"tls_client_cert_required": true,
+	"unix_socket_trusted":      true,
+}
+
+// defaults values used when a key isn't set by any other source.
+var defaults = resolved{
+	"breaker_cooldown":            "30s",
+	"breaker_threshold":           "5",
+	"config_reload_interval":      "30s",
+	"credentials_reload_interval": "30s",
+	"health_check_interval":       "15s",
+	"lockout_duration":            "1m",
+	"lockout_max_duration":        "1h",
+	"lockout_threshold":           "5",
+	"oidc_name_claim":             "sub",
+	"oidc_roles_claim":            "roles",
+	"port":                        "8000",
+	"power_on_concurrency":        "0",
+	"power_on_stagger":            "0s",
+	"retry_attempts":              "3",
+	"retry_backoff":               "500ms",
+	"retry_timeout":               "60s",
+	"shutdown_timeout":            "25s",
+	"tls_min_version":             "1.2",
+	"vsphere_max_concurrency":     "8",
+	"vsphere_rate_burst":          "20",
+	"vsphere_rate_limit":          "10",
+	"webhook_signature_header":    "X-Signature",
+}
+
+// variables environment variable for each key.
+var variables = map[string]string{
+	"admin_allow":                 "ADMIN_ALLOW",
+	"admin_bind_address":          "ADMIN_BIND_ADDRESS",
+	"admin_deny":                  "ADMIN_DENY",
+	"admin_port":                  "ADMIN_PORT",
+	"api_passthrough":             "API_PASSTHROUGH",
+	"basic_fallback":              "AUTH_BASIC_FALLBACK",
+	"bind_address":                "BRIDGE_BIND_ADDRESS",
+	"breaker_cooldown":            "BREAKER_COOLDOWN",
+	"breaker_threshold":           "BREAKER_THRESHOLD",
+	"config":                      "BRIDGE_CONFIG",
+	"config_reload_interval":      "CONFIG_RELOAD_INTERVAL",
+	"credentials_file":            "VSPHERE_CREDENTIALS_FILE",
+	"credentials_key":             "CREDENTIALS_KEY",
+	"credentials_key_file":        "CREDENTIALS_KEY_FILE",
+	"credentials_reload_interval": "CREDENTIALS_RELOAD_INTERVAL",
+	"debug":                       "BRIDGE_DEBUG",
+	"fqdn":                        "VSPHERE_FQDN",
+	"health_allow":                "HEALTH_ALLOW",
+	"health_check_interval":       "HEALTH_CHECK_INTERVAL",
+	"health_deny":                 "HEALTH_DENY",
+	"insecure":                    "ALLOW_INSECURE",
+	"lockout_duration":            "LOCKOUT_DURATION",
+	"lockout_max_duration":        "LOCKOUT_MAX_DURATION",
+	"lockout_threshold":           "LOCKOUT_THRESHOLD",
+	"notify_url":                  "NOTIFY_URL",
+	"oidc_audience":               "OIDC_AUDIENCE",
+	"oidc_issuer":                 "OIDC_ISSUER",
+	"oidc_jwks_file":              "OIDC_JWKS_FILE",
+	"oidc_jwks_url":               "OIDC_JWKS_URL",
+	"oidc_name_claim":             "OIDC_NAME_CLAIM",
+	"oidc_role_mapping":           "OIDC_ROLE_MAPPING",
+	"oidc_roles_claim":            "OIDC_ROLES_CLAIM",
+	"password":                    "VSPHERE_PASSWORD",
+	"password_file":               "VSPHERE_PASSWORD_FILE",
+	"policy_file":                 "POLICY_FILE",
+	"port":                        "BRIDGE_PORT",
+	"power_allow":                 "POWER_ALLOW",
+	"power_deny":                  "POWER_DENY",
+	"power_limits":                "POWER_LIMITS",
+	"power_on_concurrency":        "POWER_ON_CONCURRENCY",
+	"power_on_priorities":         "POWER_ON_PRIORITIES",
+	"power_on_stagger":            "POWER_ON_STAGGER",
+	"quota_file":                  "QUOTA_FILE",
+	"retry_attempts":              "RETRY_ATTEMPTS",
+	"retry_backoff":               "RETRY_BACKOFF",
+	"retry_timeout":               "RETRY_TIMEOUT",
+	"secrets_key":                 "SECRETS_KEY",
+	"secrets_key_file":            "SECRETS_KEY_FILE",
+	"shutdown_timeout":            "SHUTDOWN_TIMEOUT",
+	"signing_secret":              "SIGNING_SECRET",
+	"tls_cert_file":               "TLS_CERT_FILE",
+	"tls_client_ca_file":          "TLS_CLIENT_CA_FILE",
+	"tls_client_cert_required":    "TLS_CLIENT_CERT_REQUIRED",
+	"tls_key_file":                "TLS_KEY_FILE",
+	"tls_min_version":             "TLS_MIN_VERSION",
+	"trusted_proxies":             "TRUSTED_PROXIES",
+	"unix_socket":                 "BRIDGE_UNIX_SOCKET",
+	"unix_socket_trusted":         "BRIDGE_UNIX_SOCKET_TRUSTED",
+	"username":                    "VSPHERE_USERNAME",
+	"username_file":               "VSPHERE_USERNAME_FILE",
+	"vsphere_max_concurrency":     "VSPHERE_MAX_CONCURRENCY",
+	"vsphere_proxy":               "VSPHERE_PROXY",
+	"vsphere_rate_burst":          "VSPHERE_RATE_BURST",
+	"vsphere_rate_limit":          "VSPHERE_RATE_LIMIT",
+	"webhook_secret":              "WEBHOOK_SECRET",
+	"webhook_signature_header":    "WEBHOOK_SIGNATURE_HEADER",
+}
+
+// Resolve configuration from defaults, configuration file, environment and command arguments.
 func Resolve() (*Configuration, error) {
+	config, _, err := resolve(os.Args[1:])
+
+	return config, err
+}
+
+// Inspect resolve configuration from arguments and report the effective value and source of each key, secrets are
+// redacted.
+func Inspect(args []string) (*Configuration, []Setting, error) {
+	config, values, err := resolve(args)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys := make([]string, 0, len(variables))
+	for key := range variables {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	settings := make([]Setting, 0, len(keys))
+	for _, key := range keys {
+		value, origin := values.lookup(key)
+		if value != "" && (sensitive[key] || origin.decrypted[key]) {
+			value = redacted
+		}
+
+		settings = append(settings, Setting{Key: key, Source: origin.name, Value: value})
+	}
+
+	return config, settings, nil
+}
+
+// resolve configuration from defaults, configuration file, environment and arguments.
+func resolve(args []string) (*Configuration, sources, error) {
+	flags, err := resolveFlags(args)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid arguments")
+	}
+
 	env := resolveEnv()
-	flags := resolveFlags()
 
-	port := strings.TrimSpace(preferFlags(flags, env, "port"))
+	// The configuration file path can't come from the configuration file
+	values := sources{{name: "flag", values: flags}, {name: "environment", values: env}}
+
+	file := resolved{}
+	if path := values.get("config"); path != "" {
+		file, err = resolveFile(path)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "invalid configuration file %s", path)
+		}
+	}
+
+	values = append(values, source{name: "file", values: file}, source{name: "default", values: defaults})
+
+	err = values.decrypt()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to decrypt configuration")
+	}
+
+	breakerCooldown, err := parseDuration(values.get("breaker_cooldown"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid breaker cooldown")
+	}
+
+	breakerThreshold, err := parseInt(values.get("breaker_threshold"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid breaker threshold")
+	}
+
+	configReloadInterval, err := parseDuration(values.get("config_reload_interval"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid config reload interval")
+	}
+
+	reloadInterval, err := parseDuration(values.get("credentials_reload_interval"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid credentials reload interval")
+	}
+
+	healthCheckInterval, err := parseDuration(values.get("health_check_interval"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid health check interval")
+	}
+
+	lockoutDuration, err := parseDuration(values.get("lockout_duration"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid lockout duration")
+	}
+
+	lockoutMaxDuration, err := parseDuration(values.get("lockout_max_duration"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid lockout max duration")
+	}
+
+	lockoutThreshold, err := parseInt(values.get("lockout_threshold"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid lockout threshold")
+	}
+
+	powerOnConcurrency, err := parseInt(values.get("power_on_concurrency"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid power on concurrency")
+	}
+
+	powerOnStagger, err := parseDuration(values.get("power_on_stagger"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid power on stagger")
+	}
+
+	retryAttempts, err := parseInt(values.get("retry_attempts"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid retry attempts")
+	}
+
+	retryBackoff, err := parseDuration(values.get("retry_backoff"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid retry backoff")
+	}
+
+	retryTimeout, err := parseDuration(values.get("retry_timeout"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid retry timeout")
+	}
+
+	shutdownTimeout, err := parseDuration(values.get("shutdown_timeout"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid shutdown timeout")
+	}
+
+	maxConcurrency, err := parseInt(values.get("vsphere_max_concurrency"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid vsphere max concurrency")
+	}
+
+	rateBurst, err := parseInt(values.get("vsphere_rate_burst"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid vsphere rate burst")
+	}
+
+	rateLimit, err := parseInt(values.get("vsphere_rate_limit"))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid vsphere rate limit")
+	}
 
-	// Prefer flags where possible
 	config := &Configuration{
-		Insecure:  truthy.Value(strings.TrimSpace(preferFlags(flags, env, "insecure"))),
-		NotifyURL: strings.TrimSpace(preferFlags(flags, env, "notify_url")),
-		Password:  strings.TrimSpace(env["password"]),
-		Port:      truthy.Cond(port != "", port, "8000"),
-		Username:  strings.TrimSpace(env["username"]),
-		fqdn:      strings.TrimSpace(strings.TrimSuffix(preferFlags(flags, env, "fqdn"), "/")),
+		AdminAllow:                values.get("admin_allow"),
+		AdminBindAddress:          values.get("admin_bind_address"),
+		AdminDeny:                 values.get("admin_deny"),
+		AdminPort:                 values.get("admin_port"),
+		APIPassthrough:            values.get("api_passthrough"),
+		BasicFallback:             parseBool(values.get("basic_fallback")),
+		BindAddress:               values.get("bind_address"),
+		BreakerCooldown:           breakerCooldown,
+		BreakerThreshold:          breakerThreshold,
+		ConfigFile:                values.get("config"),
+		ConfigReloadInterval:      configReloadInterval,
+		CredentialsFile:           values.get("credentials_file"),
+		CredentialsKey:            values.get("credentials_key"),
+		CredentialsKeyFile:        values.get("credentials_key_file"),
+		CredentialsReloadInterval: reloadInterval,
+		Debug:                     parseBool(values.get("debug")),
+		HealthAllow:               values.get("health_allow"),
+		HealthCheckInterval:       healthCheckInterval,
+		HealthDeny:                values.get("health_deny"),
+		Insecure:                  parseBool(values.get("insecure")),
+		LockoutDuration:           lockoutDuration,
+		LockoutMaxDuration:        lockoutMaxDuration,
+		LockoutThreshold:          lockoutThreshold,
+		NotifyURL:                 values.get("notify_url"),
+		OIDCAudience:              values.get("oidc_audience"),
+		OIDCIssuer:                values.get("oidc_issuer"),
+		OIDCJWKSFile:              values.get("oidc_jwks_file"),
+		OIDCJWKSURL:               values.get("oidc_jwks_url"),
+		OIDCNameClaim:             values.get("oidc_name_claim"),
+		OIDCRoleMapping:           values.get("oidc_role_mapping"),
+		OIDCRolesClaim:            values.get("oidc_roles_claim"),
+		Password:                  values.get("password"),
+		PasswordFile:              values.get("password_file"),
+		PolicyFile:                values.get("policy_file"),
+		Port:                      values.get("port"),
+		PowerAllow:                values.get("power_allow"),
+		PowerDeny:                 values.get("power_deny"),
+		PowerLimits:               values.get("power_limits"),
+		PowerOnConcurrency:        powerOnConcurrency,
+		PowerOnPriorities:         values.get("power_on_priorities"),
+		PowerOnStagger:            powerOnStagger,
+		QuotaFile:                 values.get("quota_file"),
+		RetryAttempts:             retryAttempts,
+		RetryBackoff:              retryBackoff,
+		RetryTimeout:              retryTimeout,
+		ShutdownTimeout:           shutdownTimeout,
+		SigningSecret:             values.get("signing_secret"),
+		TLSCertFile:               values.get("tls_cert_file"),
+		TLSClientCAFile:           values.get("tls_client_ca_file"),
+		TLSClientCertRequired:     parseBool(values.get("tls_client_cert_required")),
+		TLSKeyFile:                values.get("tls_key_file"),
+		TLSMinVersion:             values.get("tls_min_version"),
+		TrustedProxies:            values.get("trusted_proxies"),
+		UnixSocket:                values.get("unix_socket"),
+		UnixSocketTrusted:         parseBool(values.get("unix_socket_trusted")),
+		Username:                  values.get("username"),
+		UsernameFile:              values.get("username_file"),
+		VsphereMaxConcurrency:     maxConcurrency,
+		VsphereProxy:              values.get("vsphere_proxy"),
+		VsphereRateBurst:          rateBurst,
+		VsphereRateLimit:          rateLimit,
+		WebhookSecret:             values.get("webhook_secret"),
+		WebhookSignatureHeader:    values.get("webhook_signature_header"),
+		fqdn:                      strings.TrimSuffix(values.get("fqdn"), "/"),
+	}
+
+	err = validate(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return config, values, nil
+}
+
+// decrypt encrypted values from each source in place.
+func (s sources) decrypt() error {
+	var identities []age.Identity
+
+	for index, origin := range s {
+		s[index].decrypted = make(map[string]bool)
+
+		for key, value := range origin.values {
+			if !secrets.Encrypted(value) {
+				continue
+			}
+
+			// Only load the key if there is something to decrypt
+			if identities == nil {
+				var err error
+
+				identities, err = secrets.Identities(s.get("secrets_key"), s.get("secrets_key_file"))
+				if err != nil {
+					return errors.Wrap(err, "unable to load secrets key")
+				}
+			}
+
+			plaintext, err := secrets.DecryptValue(value, identities)
+			if err != nil {
+				return errors.Wrap(err, "unable to decrypt %s from %s", key, origin.name)
+			}
+
+			origin.values[key] = plaintext
+			s[index].decrypted[key] = true
+		}
+	}
+
+	return nil
+}
+
+// get the value of a key from the source with the highest precedence which sets it.
+func (s sources) get(key string) string {
+	value, _ := s.lookup(key)
+
+	return value
+}
+
+// lookup the value of a key and the source with the highest precedence which sets it.
+func (s sources) lookup(key string) (string, source) {
+	for _, origin := range s {
+		value := strings.TrimSpace(origin.values[key])
+		if value != "" {
+			return value, origin
+		}
+	}
+
+	return "", source{name: "unset"}
+}
+
+// parseDuration parse a non-negative duration.
+func parseDuration(value string) (time.Duration, error) {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to parse duration %s", value)
 	}
 
-	err := validate(config)
+	if duration < 0 {
+		return 0, errors.New("duration %s must not be negative", value)
+	}
+
+	return duration, nil
+}
+
+// parseInt parse a non-negative integer.
+func parseInt(value string) (int, error) {
+	number, err := strconv.Atoi(value)
 	if err != nil {
-		return nil, err
+		return 0, errors.Wrap(err, "unable to parse number %s", value)
+	}
+
+	if number < 0 {
+		return 0, errors.New("number %d must not be negative", number)
 	}
 
-	return config, nil
+	return number, nil
 }
 
-// preferFlags will return the flag key if it exists
-func preferFlags(flags resolved, env resolved, key string) string {
-	return truthy.Cond(flags[key] != "", flags[key], env[key])
+// parseBool value, any other non-empty value such as yes or on is true.
+func parseBool(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+
+	if parsed, err := strconv.ParseBool(value); err == nil {
+		return parsed
+	}
+
+	return value != "no" && value != "off" && truthy.Value(value)
 }
 
-// resolveEnv from environment variables.
+// resolveEnv from environment variables, unset variables are omitted.
 func resolveEnv() resolved {
-	return resolved{
-		"fqdn":       os.Getenv("VSPHERE_FQDN"),
-		"insecure":   truthy.Cond(truthy.Value(os.Getenv("ALLOW_INSECURE")), "true", "false"),
-		"notify_url": os.Getenv("NOTIFY_URL"),
-		"password":   os.Getenv("VSPHERE_PASSWORD"),
-		"port":       os.Getenv("BRIDGE_PORT"),
-		"username":   os.Getenv("VSPHERE_USERNAME"),
+	env := resolved{}
+
+	for key, variable := range variables {
+		value, ok := os.LookupEnv(variable)
+		if !ok || strings.TrimSpace(value) == "" {
+			continue
+		}
+
+		if booleans[key] {
+			value = truthy.Cond(parseBool(value), "true", "false")
+		}
+
+		env[key] = value
 	}
+
+	return env
 }
 
-// resolveFlags passed at run time.
-func resolveFlags() resolved {
-	var port = flag.Int("port", -1, "port to run bridge on")
-	var insecure = flag.Bool("insecure", false, "disable tls certificate verification")
-	var fqdn = flag.String("fqdn", "", "vsphere server fqdn")
-	var notifyURL = flag.String("notify-url", "", "shoutrrr compatible notify url")
-	flag.Parse()
+// resolveFlags passed at run time, flags which aren't passed are omitted so they don't override other sources.
+func resolveFlags(args []string) (resolved, error) {
+	flags := flag.NewFlagSet("bridge", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
 
-	return resolved{
-		"fqdn":       *fqdn,
-		"insecure":   truthy.Cond(*insecure, "true", "false"),
-		"notify_url": *notifyURL,
-		"port":       truthy.Cond(*port >= 0, strconv.Itoa(*port), ""),
+	flags.String("admin-allow", "", "comma separated networks allowed to access admin endpoints")
+	flags.String("admin-bind-address", "", "address to bind the admin listener to")
+	flags.String("admin-deny", "", "comma separated networks denied access to admin endpoints")
+	flags.Uint("admin-port", 0, "port to run the admin listener on")
+	flags.String("api-passthrough", "", "comma separated method and path patterns which can be sent to the vsphere api")
+	flags.Bool("basic-fallback", false, "allow basic authorization passthrough when other authentication is configured")
+	flags.String("bind-address", "", "address to bind the bridge to")
+	flags.String("breaker-cooldown", "", "time calls to vsphere are rejected once the circuit breaker opens")
+	flags.Uint("breaker-threshold", 0, "consecutive vsphere failures before the circuit breaker opens")
+	flags.String("config", "", "path to yaml or toml configuration file")
+	flags.String("config-reload-interval", "", "interval between checking for changed configuration files")
+	flags.String("credentials-file", "", "path to age encrypted credentials file")
+	flags.String("credentials-key-file", "", "path to age identity used to decrypt credentials")
+	flags.String("credentials-reload-interval", "", "interval between checking for changed credentials")
+	flags.Bool("debug", false, "include error traces in error responses")
+	flags.String("fqdn", "", "vsphere server fqdn")
+	flags.String("health-allow", "", "comma separated networks allowed to access health endpoints")
+	flags.String("health-check-interval", "", "duration vsphere readiness checks are cached for")
+	flags.String("health-deny", "", "comma separated networks denied access to health endpoints")
+	flags.Bool("insecure", false, "disable tls certificate verification")
+	flags.String("lockout-duration", "", "initial lockout after repeated authentication failures")
+	flags.String("lockout-max-duration", "", "maximum lockout after repeated authentication failures")
+	flags.Uint("lockout-threshold", 0, "authentication failures before locking out")
+	flags.String("notify-url", "", "shoutrrr compatible notify url")
+	flags.String("oidc-audience", "", "audience bearer tokens must be issued for")
+	flags.String("oidc-issuer", "", "issuer of bearer tokens")
+	flags.String("oidc-jwks-file", "", "path to jwks used to verify bearer tokens")
+	flags.String("oidc-jwks-url", "", "url of jwks used to verify bearer tokens")
+	flags.String("oidc-name-claim", "", "bearer token claim containing the client name")
+	flags.String("oidc-role-mapping", "", "comma separated claim=role pairs")
+	flags.String("oidc-roles-claim", "", "bearer token claim containing client roles")
+	flags.String("password-file", "", "path to file containing vsphere password")
+	flags.String("policy-file", "", "path to policy file")
+	flags.Uint("port", 0, "port to run bridge on")
+	flags.String("power-allow", "", "comma separated networks allowed to access power endpoints")
+	flags.String("power-deny", "", "comma separated networks denied access to power endpoints")
+	flags.String("power-limits", "", "comma separated limits on how often power actions can be performed")
+	flags.Uint("power-on-concurrency", 0, "maximum virtual machines powered on at once")
+	flags.String("power-on-priorities", "", "comma separated name=priority pairs used to order queued power ons")
+	flags.String("power-on-stagger", "", "minimum delay between virtual machines being powered on")
+	flags.String("quota-file", "", "path to file daily power limit counters are persisted to")
+	flags.Uint("retry-attempts", 0, "attempts made for vsphere calls which fail with a transient error")
+	flags.String("retry-backoff", "", "delay before the first retry of a vsphere call")
+	flags.String("retry-timeout", "", "maximum time spent on a vsphere call including retries")
+	flags.String("secrets-key-file", "", "path to age identity used to decrypt configuration values")
+	flags.String("shutdown-timeout", "", "maximum time to wait for in-flight requests when shutting down")
+	flags.String("signing-secret", "", "secret used to sign pre-signed urls")
+	flags.String("tls-cert-file", "", "path to tls certificate")
+	flags.String("tls-client-ca-file", "", "path to certificate authorities used to verify client certificates")
+	flags.Bool("tls-client-cert-required", false, "reject connections without a client certificate")
+	flags.String("tls-key-file", "", "path to tls private key")
+	flags.String("tls-min-version", "", "minimum tls version")
+	flags.String("trusted-proxies", "", "comma separated networks trusted to set X-Forwarded-For")
+	flags.String("unix-socket", "", "path to a unix socket to run the bridge on")
+	flags.Bool("unix-socket-trusted", false, "allow unix socket clients through address allow and deny lists")
+	flags.String("username-file", "", "path to file containing vsphere username")
+	flags.Uint("vsphere-max-concurrency", 0, "maximum concurrent calls to vsphere")
+	flags.String("vsphere-proxy", "", "proxy used to reach vsphere, either a url or comma separated host=url pairs")
+	flags.Uint("vsphere-rate-burst", 0, "calls to vsphere allowed in a burst above the rate limit")
+	flags.Uint("vsphere-rate-limit", 0, "calls to vsphere allowed per second")
+	flags.String("webhook-secret", "", "secret used to verify webhook signatures")
+	flags.String("webhook-signature-header", "", "header containing webhook signatures")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse flags")
 	}
+
+	values := resolved{}
+	flags.Visit(func(passed *flag.Flag) {
+		values[strings.ReplaceAll(passed.Name, "-", "_")] = passed.Value.String()
+	})
+
+	return values, nil
 }
 
 // validate configuration.
@@ -99,8 +657,50 @@ func validate(config *Configuration) error {
 		return errors.Wrap(err, "invalid port number")
 	}
 
+	if config.AdminBindAddress == "" {
+		config.AdminBindAddress = config.BindAddress
+	}
+
+	if config.AdminPort != "" {
+		_, err = strconv.Atoi(config.AdminPort)
+		if err != nil {
+			return errors.Wrap(err, "invalid admin port number")
+		}
+
+		if config.AdminPort == config.Port && config.AdminBindAddress == config.BindAddress {
+			return errors.New("admin listener must use a different address or port to the bridge")
+		}
+	}
+
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		return errors.New("tls certificate and key are both required to enable tls")
+	}
+
+	if config.TLSClientCAFile != "" && config.TLSCertFile == "" {
+		return errors.New("tls certificate and key are required when client certificate authentication is enabled")
+	}
+
+	if config.VsphereRateLimit > 0 && config.VsphereRateBurst == 0 {
+		return errors.New("vsphere rate burst must be greater than 0 when rate limiting is enabled")
+	}
+
+	if config.RetryTimeout == 0 {
+		return errors.New("retry timeout must be greater than 0")
+	}
+
+	hasUsername := config.Username != "" || config.UsernameFile != "" || config.CredentialsFile != ""
+	hasPassword := config.Password != "" || config.PasswordFile != "" || config.CredentialsFile != ""
+
+	if config.OIDCIssuer != "" && (!hasUsername || !hasPassword) {
+		return errors.New("vsphere username and password are required when oidc authentication is enabled")
+	}
+
+	// Anyone able to reach the bridge could otherwise create pre-signed urls
+	if config.SigningSecret != "" && config.OIDCIssuer == "" && config.TLSClientCAFile == "" && config.WebhookSecret == "" {
+		return errors.New("oidc, client certificate or webhook authentication is required when pre-signed urls are enabled")
+	}
+
 	config.Server = server
-	config.Port = config.Port
 
 	return nil
 }
@@ -0,0 +1,587 @@
+package configuration
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// clearEnv unset every configuration environment variable for the duration of a test, tests which call it can't run
+// in parallel.
+func clearEnv(t *testing.T) {
+	t.Helper()
+
+	for _, variable := range variables {
+		t.Setenv(variable, "")
+	}
+}
+
+// writeFile write a configuration file to a temporary directory.
+func writeFile(t *testing.T, name string, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+
+	return path
+}
+
+// sourceOf find the source which set a key.
+func sourceOf(settings []Setting, key string) Setting {
+	for _, setting := range settings {
+		if setting.Key == key {
+			return setting
+		}
+	}
+
+	return Setting{}
+}
+
+func TestResolve_Precedence(t *testing.T) {
+	file := writeFile(t, "bridge.yaml", "fqdn: https://file.example.com\nretry_attempts: 4\nport: 9000\n")
+
+	testcases := map[string]struct {
+		args     []string
+		env      map[string]string
+		attempts int
+		source   string
+	}{
+		"default": {
+			args:     []string{"--fqdn", "https://flag.example.com"},
+			attempts: 3,
+			source:   "default",
+		},
+		"file": {
+			args:     []string{"--config", file},
+			attempts: 4,
+			source:   "file",
+		},
+		"environment": {
+			args:     []string{"--config", file},
+			env:      map[string]string{"RETRY_ATTEMPTS": "5"},
+			attempts: 5,
+			source:   "environment",
+		},
+		"flag": {
+			args:     []string{"--config", file, "--retry-attempts", "6"},
+			env:      map[string]string{"RETRY_ATTEMPTS": "5"},
+			attempts: 6,
+			source:   "flag",
+		},
+		"empty environment variable": {
+			args:     []string{"--config", file},
+			env:      map[string]string{"RETRY_ATTEMPTS": " "},
+			attempts: 4,
+			source:   "file",
+		},
+		"configuration file from environment": {
+			env:      map[string]string{"BRIDGE_CONFIG": file},
+			attempts: 4,
+			source:   "file",
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			clearEnv(t)
+
+			for variable, value := range testcase.env {
+				t.Setenv(variable, value)
+			}
+
+			config, settings, err := Inspect(testcase.args)
+			require.NoError(t, err)
+
+			assert.Equal(t, testcase.attempts, config.RetryAttempts)
+			assert.Equal(t, testcase.source, sourceOf(settings, "retry_attempts").Source)
+		})
+	}
+}
+
+func TestResolve_Sources(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("VSPHERE_FQDN", "https://env.example.com")
+	t.Setenv("VSPHERE_PASSWORD", "secret")
+
+	file := writeFile(t, "bridge.toml", "fqdn = \"https://file.example.com\"\nport = 9000\nusername = \"service\"\n")
+
+	config, settings, err := Inspect([]string{"--config", file, "--port", "9100"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://env.example.com", config.Server.String())
+	assert.Equal(t, "9100", config.Port)
+	assert.Equal(t, "service", config.Username)
+
+	assert.Equal(t, Setting{Key: "fqdn", Source: "environment", Value: "https://env.example.com"}, sourceOf(settings, "fqdn"))
+	assert.Equal(t, Setting{Key: "port", Source: "flag", Value: "9100"}, sourceOf(settings, "port"))
+	assert.Equal(t, Setting{Key: "username", Source: "file", Value: "service"}, sourceOf(settings, "username"))
+	assert.Equal(t, Setting{Key: "password", Source: "environment", Value: redacted}, sourceOf(settings, "password"))
+	assert.Equal(t, Setting{Key: "retry_timeout", Source: "default", Value: "60s"}, sourceOf(settings, "retry_timeout"))
+	assert.Equal(t, Setting{Key: "policy_file", Source: "unset"}, sourceOf(settings, "policy_file"))
+}
+
+func TestResolve_Booleans(t *testing.T) {
+	testcases := map[string]struct {
+		args     []string
+		env      string
+		file     string
+		expected bool
+	}{
+		"unset": {
+			expected: false,
+		},
+		"flag": {
+			args:     []string{"--debug"},
+			expected: true,
+		},
+		"flag false": {
+			args:     []string{"--debug=false"},
+			env:      "true",
+			expected: false,
+		},
+		"environment yes": {
+			env:      "yes",
+			expected: true,
+		},
+		"environment off": {
+			env:      "off",
+			file:     "true",
+			expected: false,
+		},
+		"environment zero": {
+			env:      "0",
+			expected: false,
+		},
+		"file boolean": {
+			file:     "true",
+			expected: true,
+		},
+		"file string": {
+			file:     `"on"`,
+			expected: true,
+		},
+		"file no": {
+			file:     `"no"`,
+			expected: false,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("VSPHERE_FQDN", "https://vcenter.example.com")
+
+			if testcase.env != "" {
+				t.Setenv("BRIDGE_DEBUG", testcase.env)
+			}
+
+			args := testcase.args
+			if testcase.file != "" {
+				args = append(args, "--config", writeFile(t, "bridge.yaml", "debug: "+testcase.file+"\n"))
+			}
+
+			config, _, err := Inspect(args)
+			require.NoError(t, err)
+			assert.Equal(t, testcase.expected, config.Debug)
+		})
+	}
+}
+
+func TestResolve_Conversion(t *testing.T) {
+	testcases := map[string]struct {
+		args  []string
+		file  string
+		check func(t *testing.T, config *Configuration)
+		err   bool
+	}{
+		"durations": {
+			args: []string{"--retry-timeout", "1m30s", "--power-on-stagger", "250ms", "--lockout-duration", "0s"},
+			check: func(t *testing.T, config *Configuration) {
+				assert.Equal(t, 90*time.Second, config.RetryTimeout)
+				assert.Equal(t, 250*time.Millisecond, config.PowerOnStagger)
+				assert.Zero(t, config.LockoutDuration)
+			},
+		},
+		"numbers": {
+			args: []string{"--retry-attempts", "0", "--vsphere-rate-limit", "0", "--power-on-concurrency", "4"},
+			check: func(t *testing.T, config *Configuration) {
+				assert.Zero(t, config.RetryAttempts)
+				assert.Zero(t, config.VsphereRateLimit)
+				assert.Equal(t, 4, config.PowerOnConcurrency)
+			},
+		},
+		"duration without unit": {
+			args: []string{"--retry-timeout", "60"},
+			err:  true,
+		},
+		"negative duration": {
+			args: []string{"--breaker-cooldown", "-1s"},
+			err:  true,
+		},
+		"negative number": {
+			file: "breaker_threshold: -1\n",
+			err:  true,
+		},
+		"number with text": {
+			file: "retry_attempts: many\n",
+			err:  true,
+		},
+		"unknown flag": {
+			args: []string{"--unknown"},
+			err:  true,
+		},
+		"number flag with text": {
+			args: []string{"--retry-attempts", "many"},
+			err:  true,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("VSPHERE_FQDN", "https://vcenter.example.com")
+
+			args := testcase.args
+			if testcase.file != "" {
+				args = append(args, "--config", writeFile(t, "bridge.yaml", testcase.file))
+			}
+
+			config, _, err := Inspect(args)
+			if testcase.err {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			testcase.check(t, config)
+		})
+	}
+}
+
+func TestResolve_EveryKey(t *testing.T) {
+	server, err := url.Parse("https://vcenter.example.com")
+	require.NoError(t, err)
+
+	expected := &Configuration{
+		AdminAllow:                "10.0.0.0/8,192.168.1.10",
+		AdminBindAddress:          "127.0.0.1",
+		AdminDeny:                 "10.0.0.5",
+		AdminPort:                 "8001",
+		APIPassthrough:            "GET /vcenter/vm/**",
+		BasicFallback:             true,
+		BindAddress:               "0.0.0.0",
+		BreakerCooldown:           45 * time.Second,
+		BreakerThreshold:          7,
+		ConfigReloadInterval:      time.Minute,
+		CredentialsFile:           "/etc/bridge/credentials.age",
+		CredentialsKey:            "AGE-SECRET-KEY-EXAMPLE",
+		CredentialsKeyFile:        "/etc/bridge/credentials.key",
+		CredentialsReloadInterval: 2 * time.Minute,
+		Debug:                     true,
+		HealthAllow:               "10.0.0.0/8",
+		HealthCheckInterval:       20 * time.Second,
+		HealthDeny:                "10.0.0.6",
+		Insecure:                  false,
+		LockoutDuration:           2 * time.Minute,
+		LockoutMaxDuration:        2 * time.Hour,
+		LockoutThreshold:          4,
+		NotifyURL:                 "generic://notify.example.com",
+		OIDCAudience:              "bridge",
+		OIDCIssuer:                "https://issuer.example.com",
+		OIDCJWKSFile:              "/etc/bridge/jwks.json",
+		OIDCJWKSURL:               "https://issuer.example.com/jwks",
+		OIDCNameClaim:             "preferred_username",
+		OIDCRoleMapping:           "vsphere-admins=admin",
+		OIDCRolesClaim:            "groups",
+		Password:                  "secret",
+		PasswordFile:              "/etc/bridge/password",
+		PolicyFile:                "/etc/bridge/policy.yaml",
+		Port:                      "8443",
+		PowerAllow:                "10.1.0.0/16",
+		PowerDeny:                 "10.1.0.5",
+		PowerLimits:               "client:reset=10/1h",
+		PowerOnConcurrency:        3,
+		PowerOnPriorities:         "dc*=100",
+		PowerOnStagger:            5 * time.Second,
+		QuotaFile:                 "/var/lib/bridge/quota.json",
+		RetryAttempts:             6,
+		RetryBackoff:              250 * time.Millisecond,
+		RetryTimeout:              90 * time.Second,
+		Server:                    server,
+		ShutdownTimeout:           40 * time.Second,
+		SigningSecret:             "signing",
+		TLSCertFile:               "/etc/bridge/tls.crt",
+		TLSClientCAFile:           "/etc/bridge/ca.crt",
+		TLSClientCertRequired:     true,
+		TLSKeyFile:                "/etc/bridge/tls.key",
+		TLSMinVersion:             "1.3",
+		TrustedProxies:            "10.2.0.1,10.2.0.2",
+		UnixSocket:                "/run/bridge.sock",
+		UnixSocketTrusted:         true,
+		Username:                  "service",
+		UsernameFile:              "/etc/bridge/username",
+		VsphereMaxConcurrency:     12,
+		VsphereProxy:              "socks5://jump:1080",
+		VsphereRateBurst:          30,
+		VsphereRateLimit:          15,
+		WebhookSecret:             "webhook",
+		WebhookSignatureHeader:    "X-Hook-Signature",
+		fqdn:                      "https://vcenter.example.com",
+	}
+
+	for _, name := range []string{"every-key.yaml", "every-key.toml"} {
+		t.Run(name, func(t *testing.T) {
+			clearEnv(t)
+
+			path := filepath.Join("testdata", name)
+
+			// Every key except the configuration file itself and the secrets key, which can't be read from a file it
+			// decrypts, can be set in a configuration file
+			values, err := resolveFile(path)
+			require.NoError(t, err)
+
+			for key := range variables {
+				if key == "config" || key == "secrets_key" {
+					continue
+				}
+
+				assert.Contains(t, values, key, "configuration files can't set %s", key)
+			}
+
+			config, settings, err := Inspect([]string{"--config", path})
+			require.NoError(t, err)
+
+			for _, setting := range settings {
+				if setting.Key == "config" {
+					assert.Equal(t, "flag", setting.Source)
+
+					continue
+				}
+
+				if setting.Key != "secrets_key" {
+					assert.Equal(t, "file", setting.Source, "%s wasn't read from the file", setting.Key)
+				}
+			}
+
+			expected.ConfigFile = path
+			assert.Equal(t, expected, config)
+		})
+	}
+}
+
+func TestResolveFile(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		name     string
+		contents string
+		expected resolved
+		err      bool
+	}{
+		"yaml": {
+			name:     "bridge.yaml",
+			contents: "fqdn: https://vcenter.example.com\nport: 8443\ndebug: true\nretry_backoff: 1.5s\n",
+			expected: resolved{"debug": "true", "fqdn": "https://vcenter.example.com", "port": "8443", "retry_backoff": "1.5s"},
+		},
+		"yml": {
+			name:     "bridge.yml",
+			contents: "trusted_proxies:\n  - 10.0.0.1\n  - 10.0.0.2\n",
+			expected: resolved{"trusted_proxies": "10.0.0.1,10.0.0.2"},
+		},
+		"toml": {
+			name:     "bridge.toml",
+			contents: "port = 8443\ninsecure = false\nvsphere_rate_limit = 2.5\npower_allow = [\"10.0.0.0/8\", \"192.168.1.1\"]\n",
+			expected: resolved{"insecure": "false", "port": "8443", "power_allow": "10.0.0.0/8,192.168.1.1", "vsphere_rate_limit": "2.5"},
+		},
+		"empty yaml": {
+			name:     "bridge.yaml",
+			contents: "",
+			expected: resolved{},
+		},
+		"empty values": {
+			name:     "bridge.yaml",
+			contents: "fqdn: \"\"\nport:\n",
+			expected: resolved{},
+		},
+		"unknown yaml key": {
+			name:     "bridge.yaml",
+			contents: "fqdn: https://vcenter.example.com\nhost: vcenter\n",
+			err:      true,
+		},
+		"unknown toml key": {
+			name:     "bridge.toml",
+			contents: "host = \"vcenter\"\n",
+			err:      true,
+		},
+		"invalid yaml": {
+			name:     "bridge.yaml",
+			contents: "fqdn: [\n",
+			err:      true,
+		},
+		"invalid toml": {
+			name:     "bridge.toml",
+			contents: "fqdn = \n",
+			err:      true,
+		},
+		"nested value": {
+			name:     "bridge.yaml",
+			contents: "power_limits:\n  client: 10/1h\n",
+			err:      true,
+		},
+		"unsupported extension": {
+			name:     "bridge.json",
+			contents: "{}",
+			err:      true,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			values, err := resolveFile(writeFile(t, testcase.name, testcase.contents))
+			if testcase.err {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testcase.expected, values)
+		})
+	}
+}
+
+func TestParseBool(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]bool{
+		"":      false,
+		"0":     false,
+		"1":     true,
+		"false": false,
+		"FALSE": false,
+		"no":    false,
+		"off":   false,
+		"on":    true,
+		"t":     true,
+		"true":  true,
+		" Yes ": true,
+	}
+
+	for value, expected := range testcases {
+		t.Run(value, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, expected, parseBool(value))
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	valid := func() *Configuration {
+		return &Configuration{Port: "8000", RetryTimeout: time.Minute, fqdn: "https://vcenter.example.com"}
+	}
+
+	testcases := map[string]struct {
+		change func(config *Configuration)
+		err    bool
+	}{
+		"valid": {
+			change: func(*Configuration) {},
+		},
+		"missing fqdn": {
+			change: func(config *Configuration) { config.fqdn = "" },
+			err:    true,
+		},
+		"invalid fqdn": {
+			change: func(config *Configuration) { config.fqdn = "https://vcenter example.com:port" },
+			err:    true,
+		},
+		"invalid port": {
+			change: func(config *Configuration) { config.Port = "http" },
+			err:    true,
+		},
+		"invalid admin port": {
+			change: func(config *Configuration) { config.AdminPort = "admin" },
+			err:    true,
+		},
+		"admin listener on bridge address": {
+			change: func(config *Configuration) { config.AdminPort = "8000" },
+			err:    true,
+		},
+		"admin listener on another address": {
+			change: func(config *Configuration) {
+				config.AdminPort = "8000"
+				config.AdminBindAddress = "127.0.0.1"
+			},
+		},
+		"tls certificate without key": {
+			change: func(config *Configuration) { config.TLSCertFile = "tls.crt" },
+			err:    true,
+		},
+		"tls key without certificate": {
+			change: func(config *Configuration) { config.TLSKeyFile = "tls.key" },
+			err:    true,
+		},
+		"client certificates without tls": {
+			change: func(config *Configuration) { config.TLSClientCAFile = "ca.crt" },
+			err:    true,
+		},
+		"rate limit without burst": {
+			change: func(config *Configuration) { config.VsphereRateLimit = 10 },
+			err:    true,
+		},
+		"no retry timeout": {
+			change: func(config *Configuration) { config.RetryTimeout = 0 },
+			err:    true,
+		},
+		"oidc without credentials": {
+			change: func(config *Configuration) { config.OIDCIssuer = "https://issuer.example.com" },
+			err:    true,
+		},
+		"oidc with credentials file": {
+			change: func(config *Configuration) {
+				config.OIDCIssuer = "https://issuer.example.com"
+				config.CredentialsFile = "credentials.age"
+			},
+		},
+		"signing without authentication": {
+			change: func(config *Configuration) { config.SigningSecret = "secret" },
+			err:    true,
+		},
+		"signing with webhooks": {
+			change: func(config *Configuration) {
+				config.SigningSecret = "secret"
+				config.WebhookSecret = "secret"
+			},
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := valid()
+			testcase.change(config)
+
+			err := validate(config)
+			if testcase.err {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "vcenter.example.com", config.Server.Host)
+		})
+	}
+}
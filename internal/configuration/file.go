@@ -0,0 +1,203 @@
+package configuration
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+// document configuration file representation, keys match flag names with underscores instead of dashes.
+type document struct {
+	AdminAllow                any `toml:"admin_allow" yaml:"admin_allow"`
+	AdminBindAddress          any `toml:"admin_bind_address" yaml:"admin_bind_address"`
+	AdminDeny                 any `toml:"admin_deny" yaml:"admin_deny"`
+	AdminPort                 any `toml:"admin_port" yaml:"admin_port"`
+	APIPassthrough            any `toml:"api_passthrough" yaml:"api_passthrough"`
+	BasicFallback             any `toml:"basic_fallback" yaml:"basic_fallback"`
+	BindAddress               any `toml:"bind_address" yaml:"bind_address"`
+	BreakerCooldown           any `toml:"breaker_cooldown" yaml:"breaker_cooldown"`
+	BreakerThreshold          any `toml:"breaker_threshold" yaml:"breaker_threshold"`
+	ConfigReloadInterval      any `toml:"config_reload_interval" yaml:"config_reload_interval"`
+	CredentialsFile           any `toml:"credentials_file" yaml:"credentials_file"`
+	CredentialsKey            any `toml:"credentials_key" yaml:"credentials_key"`
+	CredentialsKeyFile        any `toml:"credentials_key_file" yaml:"credentials_key_file"`
+	CredentialsReloadInterval any `toml:"credentials_reload_interval" yaml:"credentials_reload_interval"`
+	Debug                     any `toml:"debug" yaml:"debug"`
+	FQDN                      any `toml:"fqdn" yaml:"fqdn"`
+	HealthAllow               any `toml:"health_allow" yaml:"health_allow"`
+	HealthCheckInterval       any `toml:"health_check_interval" yaml:"health_check_interval"`
+	HealthDeny                any `toml:"health_deny" yaml:"health_deny"`
+	Insecure                  any `toml:"insecure" yaml:"insecure"`
+	LockoutDuration           any `toml:"lockout_duration" yaml:"lockout_duration"`
+	LockoutMaxDuration        any `toml:"lockout_max_duration" yaml:"lockout_max_duration"`
+	LockoutThreshold          any `toml:"lockout_threshold" yaml:"lockout_threshold"`
+	NotifyURL                 any `toml:"notify_url" yaml:"notify_url"`
+	OIDCAudience              any `toml:"oidc_audience" yaml:"oidc_audience"`
+	OIDCIssuer                any `toml:"oidc_issuer" yaml:"oidc_issuer"`
+	OIDCJWKSFile              any `toml:"oidc_jwks_file" yaml:"oidc_jwks_file"`
+	OIDCJWKSURL               any `toml:"oidc_jwks_url" yaml:"oidc_jwks_url"`
+	OIDCNameClaim             any `toml:"oidc_name_claim" yaml:"oidc_name_claim"`
+	OIDCRoleMapping           any `toml:"oidc_role_mapping" yaml:"oidc_role_mapping"`
+	OIDCRolesClaim            any `toml:"oidc_roles_claim" yaml:"oidc_roles_claim"`
+	Password                  any `toml:"password" yaml:"password"`
+	PasswordFile              any `toml:"password_file" yaml:"password_file"`
+	PolicyFile                any `toml:"policy_file" yaml:"policy_file"`
+	Port                      any `toml:"port" yaml:"port"`
+	PowerAllow                any `toml:"power_allow" yaml:"power_allow"`
+	PowerDeny                 any `toml:"power_deny" yaml:"power_deny"`
+	PowerLimits               any `toml:"power_limits" yaml:"power_limits"`
+	PowerOnConcurrency        any `toml:"power_on_concurrency" yaml:"power_on_concurrency"`
+	PowerOnPriorities         any `toml:"power_on_priorities" yaml:"power_on_priorities"`
+	PowerOnStagger            any `toml:"power_on_stagger" yaml:"power_on_stagger"`
+	QuotaFile                 any `toml:"quota_file" yaml:"quota_file"`
+	RetryAttempts             any `toml:"retry_attempts" yaml:"retry_attempts"`
+	RetryBackoff              any `toml:"retry_backoff" yaml:"retry_backoff"`
+	RetryTimeout              any `toml:"retry_timeout" yaml:"retry_timeout"`
+	SecretsKeyFile            any `toml:"secrets_key_file" yaml:"secrets_key_file"`
+	ShutdownTimeout           any `toml:"shutdown_timeout" yaml:"shutdown_timeout"`
+	SigningSecret             any `toml:"signing_secret" yaml:"signing_secret"`
+	TLSCertFile               any `toml:"tls_cert_file" yaml:"tls_cert_file"`
+	TLSClientCAFile           any `toml:"tls_client_ca_file" yaml:"tls_client_ca_file"`
+	TLSClientCertRequired     any `toml:"tls_client_cert_required" yaml:"tls_client_cert_required"`
+	TLSKeyFile                any `toml:"tls_key_file" yaml:"tls_key_file"`
+	TLSMinVersion             any `toml:"tls_min_version" yaml:"tls_min_version"`
+	TrustedProxies            any `toml:"trusted_proxies" yaml:"trusted_proxies"`
+	UnixSocket                any `toml:"unix_socket" yaml:"unix_socket"`
+	UnixSocketTrusted         any `toml:"unix_socket_trusted" yaml:"unix_socket_trusted"`
+	Username                  any `toml:"username" yaml:"username"`
+	UsernameFile              any `toml:"username_file" yaml:"username_file"`
+	VsphereMaxConcurrency     any `toml:"vsphere_max_concurrency" yaml:"vsphere_max_concurrency"`
+	VsphereProxy              any `toml:"vsphere_proxy" yaml:"vsphere_proxy"`
+	VsphereRateBurst          any `toml:"vsphere_rate_burst" yaml:"vsphere_rate_burst"`
+	VsphereRateLimit          any `toml:"vsphere_rate_limit" yaml:"vsphere_rate_limit"`
+	WebhookSecret             any `toml:"webhook_secret" yaml:"webhook_secret"`
+	WebhookSignatureHeader    any `toml:"webhook_signature_header" yaml:"webhook_signature_header"`
+}
+
+// resolveFile from a yaml or toml configuration file, unknown keys are rejected.
+func resolveFile(path string) (resolved, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read file")
+	}
+
+	var configFile document
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = decodeTOML(contents, &configFile)
+	case ".yaml", ".yml":
+		err = decodeYAML(contents, &configFile)
+	default:
+		return nil, errors.New("unsupported file type %s, expected .toml, .yaml or .yml", filepath.Ext(path))
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	values := resolved{}
+	fields := reflect.ValueOf(configFile)
+
+	for index := range fields.NumField() {
+		key := fields.Type().Field(index).Tag.Get("yaml")
+
+		value, err := stringify(fields.Field(index).Interface())
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid value for %s", key)
+		}
+
+		if value != "" {
+			values[key] = value
+		}
+	}
+
+	return values, nil
+}
+
+// decodeTOML decode toml, reporting the position of errors.
+func decodeTOML(contents []byte, configFile *document) error {
+	decoder := toml.NewDecoder(bytes.NewReader(contents))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(configFile)
+	if err == nil {
+		return nil
+	}
+
+	var strictError *toml.StrictMissingError
+	if errors.As(err, &strictError) {
+		messages := make([]string, 0, len(strictError.Errors))
+		for _, unknown := range strictError.Errors {
+			row, _ := unknown.Position()
+			messages = append(messages, fmt.Sprintf("line %d: unknown key %s", row, strings.Join(unknown.Key(), ".")))
+		}
+
+		return errors.New(strings.Join(messages, ", "))
+	}
+
+	var decodeError *toml.DecodeError
+	if errors.As(err, &decodeError) {
+		row, column := decodeError.Position()
+
+		return errors.Wrap(err, "line %d column %d", row, column)
+	}
+
+	return errors.Wrap(err, "unable to parse toml")
+}
+
+// decodeYAML decode yaml, errors from the yaml package include line numbers.
+func decodeYAML(contents []byte, configFile *document) error {
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+
+	err := decoder.Decode(configFile)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return errors.Wrap(err, "unable to parse yaml")
+	}
+
+	return nil
+}
+
+// stringify convert a decoded value to the string representation used by other sources.
+func stringify(value any) (string, error) {
+	switch valueType := value.(type) {
+	case nil:
+		return "", nil
+	case bool:
+		return strconv.FormatBool(valueType), nil
+	case float64:
+		return strconv.FormatFloat(valueType, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(valueType), nil
+	case int64:
+		return strconv.FormatInt(valueType, 10), nil
+	case string:
+		return valueType, nil
+	case []any:
+		// Lists are joined to match comma separated environment variables and flags
+		items := make([]string, 0, len(valueType))
+		for _, item := range valueType {
+			text, err := stringify(item)
+			if err != nil {
+				return "", err
+			}
+
+			items = append(items, text)
+		}
+
+		return strings.Join(items, ","), nil
+	default:
+		return "", errors.New("expected a string, number, boolean or list")
+	}
+}
@@ -0,0 +1,189 @@
+package configuration
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+	"github.com/sjdaws/vsphere-bridge/pkg/notifier"
+)
+
+// Preparer validate a configuration and return a function which applies it.
+type Preparer func(config *Configuration) (func(), error)
+
+// Reloader resolves configuration again when signalled or when configuration files change.
+type Reloader struct {
+	current   atomic.Pointer[Configuration]
+	logger    logging.Logger
+	modified  map[string]time.Time
+	mutex     sync.Mutex
+	notify    *notifier.Notifier
+	preparers []Preparer
+}
+
+// NewReloader create a new Reloader for a resolved configuration.
+func NewReloader(config *Configuration, logger logging.Logger, notify *notifier.Notifier) *Reloader {
+	reloader := &Reloader{
+		logger: logger,
+		notify: notify,
+	}
+
+	reloader.current.Store(config)
+	reloader.modified = modified(config)
+
+	return reloader
+}
+
+// Current configuration.
+func (r *Reloader) Current() *Configuration {
+	return r.current.Load()
+}
+
+// OnReload register a function which prepares a new configuration before it is applied.
+func (r *Reloader) OnReload(preparer Preparer) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.preparers = append(r.preparers, preparer)
+}
+
+// Reload resolve configuration and apply it, nothing is applied unless every preparer accepts the new configuration.
+func (r *Reloader) Reload() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	config, err := Resolve()
+	if err != nil {
+		return errors.Wrap(err, "unable to resolve configuration")
+	}
+
+	appliers := make([]func(), 0, len(r.preparers))
+	for _, preparer := range r.preparers {
+		apply, err := preparer(config)
+		if err != nil {
+			return errors.Wrap(err, "configuration rejected")
+		}
+
+		if apply != nil {
+			appliers = append(appliers, apply)
+		}
+	}
+
+	previous := r.current.Load()
+	for _, apply := range appliers {
+		apply()
+	}
+
+	r.current.Store(config)
+	r.modified = modified(config)
+
+	// Listeners and intervals are set up once at startup
+	if previous.Port != config.Port || previous.BindAddress != config.BindAddress || previous.AdminPort != config.AdminPort ||
+		previous.AdminBindAddress != config.AdminBindAddress || previous.UnixSocket != config.UnixSocket ||
+		previous.TLSCertFile != config.TLSCertFile || previous.TLSKeyFile != config.TLSKeyFile ||
+		previous.TLSClientCAFile != config.TLSClientCAFile || previous.TLSMinVersion != config.TLSMinVersion ||
+		previous.TLSClientCertRequired != config.TLSClientCertRequired {
+		r.logger.Warn("listener configuration changed, restart the bridge to apply")
+	}
+
+	if previous.ConfigReloadInterval != config.ConfigReloadInterval || previous.CredentialsReloadInterval != config.CredentialsReloadInterval {
+		r.logger.Warn("reload intervals changed, restart the bridge to apply")
+	}
+
+	return nil
+}
+
+// Watch reload configuration on SIGHUP or when configuration files change until the context is cancelled.
+func (r *Reloader) Watch(ctx context.Context) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	// A nil channel blocks forever, disabling polling
+	var poll <-chan time.Time
+	if interval := r.Current().ConfigReloadInterval; interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangup:
+			r.report("SIGHUP")
+		case <-poll:
+			if r.changed() {
+				r.report("file change")
+			}
+		}
+	}
+}
+
+// changed determine if any watched file has been modified since it was last checked, so a file which fails to
+// load isn't retried until it changes again.
+func (r *Reloader) changed() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	times := modified(r.current.Load())
+
+	changed := false
+	for path, last := range times {
+		if !last.Equal(r.modified[path]) {
+			changed = true
+		}
+	}
+
+	r.modified = times
+
+	return changed
+}
+
+// report the outcome of a reload through the logger and notifier.
+func (r *Reloader) report(trigger string) {
+	err := r.Reload()
+	if err != nil {
+		r.logger.Error(errors.Wrap(err, "configuration reload triggered by %s failed, continuing with previous configuration", trigger))
+
+		if r.notify != nil {
+			r.notify.Message("vsphere bridge configuration reload failed: " + err.Error())
+		}
+
+		return
+	}
+
+	r.logger.Info("configuration reloaded after %s", trigger)
+
+	if r.notify != nil {
+		r.notify.Message("vsphere bridge configuration reloaded")
+	}
+}
+
+// modified time of each file referenced by a configuration, missing files have a zero time.
+func modified(config *Configuration) map[string]time.Time {
+	times := make(map[string]time.Time)
+
+	for _, path := range []string{config.ConfigFile, config.PolicyFile} {
+		if path == "" {
+			continue
+		}
+
+		times[path] = time.Time{}
+
+		info, err := os.Stat(path)
+		if err == nil {
+			times[path] = info.ModTime()
+		}
+	}
+
+	return times
+}
@@ -0,0 +1,168 @@
+package credentials
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+// Credentials used to authenticate with vsphere.
+type Credentials struct {
+	Password string
+	Username string
+}
+
+// Provider source of credentials.
+type Provider interface {
+	Credentials() (Credentials, error)
+	Name() string
+}
+
+// Store current credentials which are refreshed from a provider.
+type Store struct {
+	current   Credentials
+	interval  time.Duration
+	listeners []func()
+	logger    logging.Logger
+	mutex     sync.RWMutex
+	provider  Provider
+}
+
+// New create a new Store using the provider selected by configuration.
+func New(config *configuration.Configuration, logger logging.Logger) (*Store, error) {
+	provider, err := newProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := provider.Credentials()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load credentials from %s", provider.Name())
+	}
+
+	return &Store{
+		current:  current,
+		interval: config.CredentialsReloadInterval,
+		logger:   logger,
+		provider: provider,
+	}, nil
+}
+
+// newProvider create the provider selected by configuration.
+func newProvider(config *configuration.Configuration) (Provider, error) {
+	switch {
+	case config.CredentialsFile != "":
+		encrypted, err := newEncryptedFile(config.CredentialsFile, config.CredentialsKey, config.CredentialsKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to configure encrypted credentials file")
+		}
+
+		return encrypted, nil
+	case config.UsernameFile != "" || config.PasswordFile != "":
+		return &file{
+			fallback:     Credentials{Password: config.Password, Username: config.Username},
+			passwordFile: config.PasswordFile,
+			usernameFile: config.UsernameFile,
+		}, nil
+	default:
+		return &environment{credentials: Credentials{Password: config.Password, Username: config.Username}}, nil
+	}
+}
+
+// Get current credentials.
+func (s *Store) Get() Credentials {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.current
+}
+
+// OnChange register a function which is called when credentials change.
+func (s *Store) OnChange(listener func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.listeners = append(s.listeners, listener)
+}
+
+// Prepare load credentials from the provider selected by a configuration and return a function which applies them.
+func (s *Store) Prepare(config *configuration.Configuration) (func(), error) {
+	provider, err := newProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := provider.Credentials()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load credentials from %s", provider.Name())
+	}
+
+	return func() {
+		s.mutex.Lock()
+		s.provider = provider
+		s.mutex.Unlock()
+
+		s.update(updated)
+	}, nil
+}
+
+// Watch periodically reload credentials from the provider until the context is cancelled.
+func (s *Store) Watch(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reload()
+		}
+	}
+}
+
+// reload credentials from the provider and notify listeners if they have changed.
+func (s *Store) reload() {
+	s.mutex.RLock()
+	provider := s.provider
+	s.mutex.RUnlock()
+
+	updated, err := provider.Credentials()
+	if err != nil {
+		// Keep using the current credentials, they may still be valid
+		s.logger.Error(errors.Wrap(err, "unable to reload credentials from %s", provider.Name()))
+
+		return
+	}
+
+	s.update(updated)
+}
+
+// update current credentials and notify listeners if they have changed.
+func (s *Store) update(updated Credentials) {
+	s.mutex.Lock()
+	if updated == s.current {
+		s.mutex.Unlock()
+
+		return
+	}
+
+	s.current = updated
+	listeners := append([]func(){}, s.listeners...)
+	name := s.provider.Name()
+	s.mutex.Unlock()
+
+	s.logger.Info("credentials reloaded from %s", name)
+
+	for _, listener := range listeners {
+		listener()
+	}
+}
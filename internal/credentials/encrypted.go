@@ -0,0 +1,60 @@
+package credentials
+
+import (
+	"encoding/json"
+	"os"
+
+	"filippo.io/age"
+
+	"github.com/sjdaws/vsphere-bridge/internal/secrets"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+// encryptedFile provides credentials from an age encrypted json file.
+type encryptedFile struct {
+	identities []age.Identity
+	path       string
+}
+
+// newEncryptedFile create a new encryptedFile provider.
+func newEncryptedFile(path string, key string, keyFile string) (*encryptedFile, error) {
+	identities, err := secrets.Identities(key, keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load credentials key")
+	}
+
+	return &encryptedFile{
+		identities: identities,
+		path:       path,
+	}, nil
+}
+
+// Credentials decrypt credentials from file.
+func (e *encryptedFile) Credentials() (Credentials, error) {
+	contents, err := os.ReadFile(e.path)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "unable to read file %s", e.path)
+	}
+
+	plaintext, err := secrets.Decrypt(contents, e.identities)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "unable to decrypt file %s", e.path)
+	}
+
+	var document struct {
+		Password string `json:"password"`
+		Username string `json:"username"`
+	}
+
+	err = json.Unmarshal(plaintext, &document)
+	if err != nil {
+		return Credentials{}, errors.Wrap(err, "unable to unmarshal credentials")
+	}
+
+	return Credentials{Password: document.Password, Username: document.Username}, nil
+}
+
+// Name of provider.
+func (e *encryptedFile) Name() string {
+	return "encrypted file"
+}
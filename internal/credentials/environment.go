@@ -0,0 +1,16 @@
+package credentials
+
+// environment provides credentials set by environment variables, these can't change while running.
+type environment struct {
+	credentials Credentials
+}
+
+// Credentials get credentials.
+func (e *environment) Credentials() (Credentials, error) {
+	return e.credentials, nil
+}
+
+// Name of provider.
+func (e *environment) Name() string {
+	return "environment"
+}
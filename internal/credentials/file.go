@@ -0,0 +1,55 @@
+package credentials
+
+import (
+	"os"
+	"strings"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+// file provides credentials from files such as mounted kubernetes secrets.
+type file struct {
+	fallback     Credentials
+	passwordFile string
+	usernameFile string
+}
+
+// Credentials read credentials from files, falling back to environment variables for any file which isn't set.
+func (f *file) Credentials() (Credentials, error) {
+	credentials := f.fallback
+
+	if f.usernameFile != "" {
+		username, err := readFile(f.usernameFile)
+		if err != nil {
+			return Credentials{}, errors.Wrap(err, "unable to read username")
+		}
+
+		credentials.Username = username
+	}
+
+	if f.passwordFile != "" {
+		password, err := readFile(f.passwordFile)
+		if err != nil {
+			return Credentials{}, errors.Wrap(err, "unable to read password")
+		}
+
+		credentials.Password = password
+	}
+
+	return credentials, nil
+}
+
+// Name of provider.
+func (f *file) Name() string {
+	return "file"
+}
+
+// readFile read a file with trailing whitespace removed.
+func readFile(path string) (string, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to read file %s", path)
+	}
+
+	return strings.TrimRight(string(contents), "\r\n\t "), nil
+}
@@ -0,0 +1,214 @@
+package firewall
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/listener"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+const (
+	// Admin route group containing administrative endpoints.
+	Admin = "admin"
+
+	// Health route group containing health endpoints.
+	Health = "health"
+
+	// Power route group containing power endpoints.
+	Power = "power"
+)
+
+// Firewall restricts access to route groups by client address.
+type Firewall struct {
+	extractor atomic.Pointer[echo.IPExtractor]
+	groups    atomic.Pointer[map[string]rules]
+	logger    logging.Logger
+	trustUnix atomic.Bool
+}
+
+// rules allowed and denied networks for a route group.
+type rules struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+// New create a new Firewall instance and configure client address extraction for each server.
+func New(config *configuration.Configuration, logger logging.Logger, servers ...*echo.Echo) (*Firewall, error) {
+	firewall := &Firewall{
+		logger: logger,
+	}
+
+	apply, err := firewall.Prepare(config)
+	if err != nil {
+		return nil, err
+	}
+
+	apply()
+
+	for _, server := range servers {
+		server.IPExtractor = func(request *http.Request) string {
+			// Unix socket clients are identified by the socket so they have an address for lockouts and limits
+			peer, ok := listener.UnixPeer(request)
+			if ok {
+				return peer
+			}
+
+			return (*firewall.extractor.Load())(request)
+		}
+	}
+
+	return firewall, nil
+}
+
+// Prepare parse address lists from a configuration and return a function which applies them.
+func (f *Firewall) Prepare(config *configuration.Configuration) (func(), error) {
+	lists := map[string][2]string{
+		Admin:  {config.AdminAllow, config.AdminDeny},
+		Health: {config.HealthAllow, config.HealthDeny},
+		Power:  {config.PowerAllow, config.PowerDeny},
+	}
+
+	groups := make(map[string]rules)
+
+	for group, list := range lists {
+		allow, err := parse(list[0])
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid %s allow list", group)
+		}
+
+		deny, err := parse(list[1])
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid %s deny list", group)
+		}
+
+		groups[group] = rules{allow: allow, deny: deny}
+	}
+
+	proxies, err := parse(config.TrustedProxies)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid trusted proxies")
+	}
+
+	// Only trust X-Forwarded-For when it is set by a trusted proxy, otherwise clients could spoof their address
+	extractor := echo.ExtractIPDirect()
+	if len(proxies) > 0 {
+		options := []echo.TrustOption{echo.TrustLinkLocal(false), echo.TrustLoopback(false), echo.TrustPrivateNet(false)}
+		for _, proxy := range proxies {
+			options = append(options, echo.TrustIPRange(proxy))
+		}
+
+		extractor = echo.ExtractIPFromXFFHeader(options...)
+	}
+
+	restricted := make([]string, 0)
+	for _, group := range []string{Admin, Health, Power} {
+		if len(groups[group].allow) > 0 || len(groups[group].deny) > 0 {
+			restricted = append(restricted, group)
+		}
+	}
+
+	return func() {
+		if config.UnixSocket != "" && !config.UnixSocketTrusted && len(restricted) > 0 {
+			f.logger.Warn("unix socket clients will be rejected by %s access lists unless unix socket clients are trusted", strings.Join(restricted, ", "))
+		}
+
+		f.extractor.Store(&extractor)
+		f.groups.Store(&groups)
+		f.trustUnix.Store(config.UnixSocketTrusted)
+	}, nil
+}
+
+// Middleware reject requests to a route group from addresses which aren't allowed.
+func (f *Firewall) Middleware(group string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			address := ctx.RealIP()
+			groupRules := (*f.groups.Load())[group]
+
+			// Access to unix sockets is controlled by file permissions, so their clients can be trusted instead
+			_, unix := listener.UnixPeer(ctx.Request())
+			if unix && f.trustUnix.Load() {
+				return next(ctx)
+			}
+
+			if !groupRules.permits(net.ParseIP(address)) {
+				f.logger.Warn("rejected %s request to %s from %s", group, ctx.Request().URL.Path, address)
+
+				return echo.NewHTTPError(http.StatusForbidden, "access denied")
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// permits determine if an address is allowed, denied networks take precedence over allowed networks.
+func (r rules) permits(address net.IP) bool {
+	if address == nil {
+		return len(r.allow) == 0 && len(r.deny) == 0
+	}
+
+	for _, network := range r.deny {
+		if network.Contains(address) {
+			return false
+		}
+	}
+
+	if len(r.allow) == 0 {
+		return true
+	}
+
+	for _, network := range r.allow {
+		if network.Contains(address) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parse a comma separated list of addresses and networks in CIDR notation.
+func parse(list string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0)
+
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		// Single addresses are treated as a network containing only that address
+		if !strings.Contains(entry, "/") {
+			address := net.ParseIP(entry)
+			if address == nil {
+				return nil, errors.New("invalid address %s", entry)
+			}
+
+			bits := 8 * net.IPv6len
+			if address.To4() != nil {
+				address = address.To4()
+				bits = 8 * net.IPv4len
+			}
+
+			networks = append(networks, &net.IPNet{IP: address, Mask: net.CIDRMask(bits, bits)})
+
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid network %s", entry)
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
@@ -0,0 +1,290 @@
+package firewall
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		list     string
+		expected []string
+		err      bool
+	}{
+		"empty": {
+			list:     "",
+			expected: []string{},
+		},
+		"bare ipv4 address": {
+			list:     "192.168.1.10",
+			expected: []string{"192.168.1.10/32"},
+		},
+		"bare ipv6 address": {
+			list:     "2001:db8::1",
+			expected: []string{"2001:db8::1/128"},
+		},
+		"cidr ranges": {
+			list:     "10.0.0.0/8, 2001:db8::/32",
+			expected: []string{"10.0.0.0/8", "2001:db8::/32"},
+		},
+		"cidr with host bits": {
+			list:     "192.168.1.10/24",
+			expected: []string{"192.168.1.0/24"},
+		},
+		"blank entries": {
+			list:     ",10.0.0.1,,",
+			expected: []string{"10.0.0.1/32"},
+		},
+		"invalid address": {
+			list: "10.0.0.256",
+			err:  true,
+		},
+		"invalid network": {
+			list: "10.0.0.0/33",
+			err:  true,
+		},
+		"hostname": {
+			list: "bridge.local",
+			err:  true,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			networks, err := parse(testcase.list)
+			if testcase.err {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			actual := make([]string, 0, len(networks))
+			for _, network := range networks {
+				actual = append(actual, network.String())
+			}
+
+			assert.Equal(t, testcase.expected, actual)
+		})
+	}
+}
+
+func TestRules_Permits(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		allow    string
+		deny     string
+		address  string
+		expected bool
+	}{
+		"empty lists": {
+			address:  "203.0.113.10",
+			expected: true,
+		},
+		"empty lists without address": {
+			expected: true,
+		},
+		"allowed": {
+			allow:    "10.0.0.0/8",
+			address:  "10.1.2.3",
+			expected: true,
+		},
+		"not allowed": {
+			allow:    "10.0.0.0/8",
+			address:  "192.168.1.1",
+			expected: false,
+		},
+		"denied": {
+			deny:     "192.168.1.0/24",
+			address:  "192.168.1.1",
+			expected: false,
+		},
+		"not denied": {
+			deny:     "192.168.1.0/24",
+			address:  "192.168.2.1",
+			expected: true,
+		},
+		"deny beats allow": {
+			allow:    "10.0.0.0/8",
+			deny:     "10.0.0.5",
+			address:  "10.0.0.5",
+			expected: false,
+		},
+		"ipv4 mapped ipv6": {
+			allow:    "10.0.0.0/8",
+			address:  "::ffff:10.0.0.1",
+			expected: true,
+		},
+		"no address with allow list": {
+			allow:    "10.0.0.0/8",
+			expected: false,
+		},
+		"no address with deny list": {
+			deny:     "10.0.0.0/8",
+			expected: false,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			allow, err := parse(testcase.allow)
+			require.NoError(t, err)
+
+			deny, err := parse(testcase.deny)
+			require.NoError(t, err)
+
+			assert.Equal(t, testcase.expected, rules{allow: allow, deny: deny}.permits(net.ParseIP(testcase.address)))
+		})
+	}
+}
+
+func TestFirewall_RealIP(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		proxies   string
+		remote    string
+		forwarded string
+		expected  string
+	}{
+		"direct": {
+			remote:   "203.0.113.10:5000",
+			expected: "203.0.113.10",
+		},
+		"forwarded without trusted proxies": {
+			remote:    "203.0.113.10:5000",
+			forwarded: "198.51.100.1",
+			expected:  "203.0.113.10",
+		},
+		"forwarded by trusted proxy": {
+			proxies:   "10.0.0.1",
+			remote:    "10.0.0.1:5000",
+			forwarded: "198.51.100.1",
+			expected:  "198.51.100.1",
+		},
+		"forwarded by untrusted proxy": {
+			proxies:   "10.0.0.1",
+			remote:    "10.0.0.2:5000",
+			forwarded: "198.51.100.1",
+			expected:  "10.0.0.2",
+		},
+		"spoofed address before trusted proxy": {
+			proxies:   "10.0.0.1",
+			remote:    "10.0.0.1:5000",
+			forwarded: "10.0.0.1, 198.51.100.1",
+			expected:  "198.51.100.1",
+		},
+		"loopback isn't trusted": {
+			proxies:   "10.0.0.1",
+			remote:    "127.0.0.1:5000",
+			forwarded: "198.51.100.1",
+			expected:  "127.0.0.1",
+		},
+		"private network isn't trusted": {
+			proxies:   "10.0.0.1",
+			remote:    "192.168.1.1:5000",
+			forwarded: "198.51.100.1",
+			expected:  "192.168.1.1",
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			logger, err := logging.New(logging.Error, io.Discard, 0)
+			require.NoError(t, err)
+
+			server := echo.New()
+
+			_, err = New(&configuration.Configuration{TrustedProxies: testcase.proxies}, logger, server)
+			require.NoError(t, err)
+
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = testcase.remote
+			if testcase.forwarded != "" {
+				request.Header.Set(echo.HeaderXForwardedFor, testcase.forwarded)
+			}
+
+			assert.Equal(t, testcase.expected, server.NewContext(request, httptest.NewRecorder()).RealIP())
+		})
+	}
+}
+
+func TestFirewall_Middleware(t *testing.T) {
+	t.Parallel()
+
+	logger, err := logging.New(logging.Error, io.Discard, 0)
+	require.NoError(t, err)
+
+	server := echo.New()
+
+	firewall, err := New(&configuration.Configuration{PowerAllow: "10.0.0.0/8", PowerDeny: "10.0.0.5"}, logger, server)
+	require.NoError(t, err)
+
+	server.GET("/power", func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	}, firewall.Middleware(Power))
+	server.GET("/health", func(ctx echo.Context) error {
+		return ctx.NoContent(http.StatusOK)
+	}, firewall.Middleware(Health))
+
+	testcases := map[string]struct {
+		path     string
+		remote   string
+		expected int
+	}{
+		"allowed": {
+			path:     "/power",
+			remote:   "10.0.0.1:5000",
+			expected: http.StatusOK,
+		},
+		"denied": {
+			path:     "/power",
+			remote:   "10.0.0.5:5000",
+			expected: http.StatusForbidden,
+		},
+		"not allowed": {
+			path:     "/power",
+			remote:   "192.168.1.1:5000",
+			expected: http.StatusForbidden,
+		},
+		"other group": {
+			path:     "/health",
+			remote:   "192.168.1.1:5000",
+			expected: http.StatusOK,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			request := httptest.NewRequest(http.MethodGet, testcase.path, nil)
+			request.RemoteAddr = testcase.remote
+
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, request)
+
+			assert.Equal(t, testcase.expected, recorder.Code)
+		})
+	}
+}
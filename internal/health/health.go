@@ -0,0 +1,58 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sjdaws/vsphere-bridge/internal/vsphere"
+)
+
+// Health reports whether the bridge is alive and able to reach vsphere.
+type Health struct {
+	vsphere *vsphere.Vsphere
+}
+
+// New create a new Health instance.
+func New(vsphere *vsphere.Vsphere, server *echo.Echo, middleware ...echo.MiddlewareFunc) *Health {
+	api := &Health{
+		vsphere: vsphere,
+	}
+
+	server.GET("/about", api.About, middleware...)
+	server.GET("/health", api.Health, middleware...)
+	server.GET("/ready", api.Ready, middleware...)
+
+	return api
+}
+
+// About report the version and capabilities of vsphere.
+func (h *Health) About(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, map[string]any{
+		"result":  "ok",
+		"targets": []vsphere.About{h.vsphere.About(ctx.Request().Context())},
+	})
+}
+
+// Health report the bridge is running, vsphere checks are included if the verbose query parameter is passed.
+func (h *Health) Health(ctx echo.Context) error {
+	_, verbose := ctx.QueryParams()["verbose"]
+	if !verbose {
+		return ctx.JSON(http.StatusOK, map[string]string{"status": "ok"})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]any{
+		"status":  "ok",
+		"targets": []vsphere.Status{h.vsphere.Status(ctx.Request().Context())},
+	})
+}
+
+// Ready report whether vsphere can be reached and authenticated with.
+func (h *Health) Ready(ctx echo.Context) error {
+	status := h.vsphere.Status(ctx.Request().Context())
+	if !status.Ready {
+		return ctx.JSON(http.StatusServiceUnavailable, map[string]any{"status": "unavailable", "targets": []vsphere.Status{status}})
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]any{"status": "ready", "targets": []vsphere.Status{status}})
+}
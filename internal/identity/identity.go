@@ -0,0 +1,42 @@
+package identity
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+// Identity of the client making a request.
+type Identity struct {
+	Method string   `json:"method"`
+	Name   string   `json:"name"`
+	Roles  []string `json:"roles"`
+}
+
+// contextKey key used to store an identity on a request context.
+const contextKey = "identity"
+
+// Anonymous identity used when a client has not identified itself.
+var Anonymous = Identity{Method: "none", Name: "anonymous", Roles: []string{}}
+
+// FromContext get the identity of the client making a request.
+func FromContext(ctx echo.Context) Identity {
+	if identity, ok := ctx.Get(contextKey).(Identity); ok {
+		return identity
+	}
+
+	// Fall back to the username sent for basic authentication passthrough
+	username, _, ok := ctx.Request().BasicAuth()
+	if ok && username != "" {
+		return Identity{Method: "basic", Name: username, Roles: []string{}}
+	}
+
+	return Anonymous
+}
+
+// Set the identity of the client making a request.
+func Set(ctx echo.Context, identity Identity) {
+	if identity.Roles == nil {
+		identity.Roles = []string{}
+	}
+
+	ctx.Set(contextKey, identity)
+}
@@ -0,0 +1,117 @@
+package listener
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+// Listeners serve handlers on one or more network listeners.
+type Listeners struct {
+	logger  logging.Logger
+	servers []server
+}
+
+// unixSocket context key used to store the path of the unix socket a connection was accepted on.
+type unixSocket struct{}
+
+// server handler attached to a listener.
+type server struct {
+	http     *http.Server
+	listener net.Listener
+}
+
+// New create a new Listeners instance.
+func New(logger logging.Logger) *Listeners {
+	return &Listeners{
+		logger: logger,
+	}
+}
+
+// Add listen on a tcp address or unix socket path and serve a handler on it once Serve is called, connections are
+// encrypted if a tls configuration is passed.
+func (l *Listeners) Add(network string, address string, handler http.Handler, config *tls.Config) error {
+	// A socket left behind by a previous run would prevent binding
+	if network == "unix" {
+		err := os.Remove(address)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "unable to remove existing socket %s", address)
+		}
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return errors.Wrap(err, "unable to listen on %s %s", network, address)
+	}
+
+	l.Attach(listener, handler, config)
+
+	return nil
+}
+
+// Attach serve a handler on an existing listener once Serve is called, connections are encrypted if a tls
+// configuration is passed.
+func (l *Listeners) Attach(listener net.Listener, handler http.Handler, config *tls.Config) {
+	served := &http.Server{Handler: handler}
+
+	// Unix socket clients don't have an address so requests are marked with the socket they arrived on
+	if listener.Addr().Network() == "unix" {
+		path := listener.Addr().String()
+		served.ConnContext = func(ctx context.Context, _ net.Conn) context.Context {
+			return context.WithValue(ctx, unixSocket{}, path)
+		}
+	}
+
+	if config != nil {
+		listener = tls.NewListener(listener, config)
+	}
+
+	l.servers = append(l.servers, server{http: served, listener: listener})
+}
+
+// UnixPeer address used for a request received on a unix socket, which identifies the socket rather than the client.
+func UnixPeer(request *http.Request) (string, bool) {
+	path, ok := request.Context().Value(unixSocket{}).(string)
+	if !ok {
+		return "", false
+	}
+
+	return "unix:" + path, true
+}
+
+// Shutdown stop accepting connections and wait for in-flight requests to complete until the context is cancelled.
+func (l *Listeners) Shutdown(ctx context.Context) error {
+	var failure error
+
+	for _, listening := range l.servers {
+		err := listening.http.Shutdown(ctx)
+		if err != nil && failure == nil {
+			failure = errors.Wrap(err, "unable to shutdown %s", listening.listener.Addr().String())
+		}
+	}
+
+	return failure
+}
+
+// Serve each listener until one of them fails.
+func (l *Listeners) Serve() error {
+	failures := make(chan error, len(l.servers))
+
+	for _, listening := range l.servers {
+		l.logger.Info("listening on %s %s", listening.listener.Addr().Network(), listening.listener.Addr().String())
+
+		go func() {
+			err := listening.http.Serve(listening.listener)
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				failures <- errors.Wrap(err, "unable to serve %s", listening.listener.Addr().String())
+			}
+		}()
+	}
+
+	return <-failures
+}
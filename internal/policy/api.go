@@ -0,0 +1,26 @@
+package policy
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+// Evaluate attributes from the request body against the policy without performing any action.
+func (p *Policy) Evaluate(ctx echo.Context) error {
+	var attributes Attributes
+
+	err := ctx.Bind(&attributes)
+	if err != nil {
+		return errors.Wrap(err, "unable to parse policy attributes")
+	}
+
+	decision, err := p.Authorize(attributes)
+	if err != nil {
+		return errors.Wrap(err, "unable to evaluate policy")
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]any{"result": "ok", "decision": decision})
+}
@@ -0,0 +1,249 @@
+package policy
+
+import (
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/labstack/echo/v4"
+	"gopkg.in/yaml.v3"
+
+	"github.com/sjdaws/vsphere-bridge/internal/identity"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+const (
+	// Allow effect which permits a request.
+	Allow = "allow"
+
+	// Deny effect which rejects a request.
+	Deny = "deny"
+)
+
+// Policy set of rules evaluated against request attributes.
+type Policy struct {
+	current atomic.Pointer[ruleset]
+}
+
+// Attributes of a request which are available to rule conditions.
+type Attributes struct {
+	Action   string            `json:"action"`
+	Client   identity.Identity `json:"client"`
+	SourceIP string            `json:"source_ip"`
+	Time     time.Time         `json:"time"`
+	VM       VirtualMachine    `json:"vm"`
+}
+
+// Decision result of evaluating attributes against a policy.
+type Decision struct {
+	Allowed bool   `json:"allowed"`
+	Message string `json:"message,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+}
+
+// VirtualMachine attributes of the virtual machine a request targets.
+type VirtualMachine struct {
+	Folder string   `json:"folder"`
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Tags   []string `json:"tags"`
+}
+
+// document policy file representation.
+type document struct {
+	Default string `yaml:"default"`
+	Rules   []struct {
+		Condition string `yaml:"condition"`
+		Effect    string `yaml:"effect"`
+		Message   string `yaml:"message"`
+		Name      string `yaml:"name"`
+	} `yaml:"rules"`
+}
+
+// ruleset compiled rules loaded from a single policy file, replaced as a whole when the policy is reloaded.
+type ruleset struct {
+	fallback string
+	rules    []rule
+}
+
+// rule compiled policy rule.
+type rule struct {
+	effect  string
+	message string
+	name    string
+	program cel.Program
+}
+
+// New load a policy from a file, an empty path creates a policy which allows everything.
+func New(path string, server *echo.Echo, middleware ...echo.MiddlewareFunc) (*Policy, error) {
+	policy := &Policy{}
+
+	apply, err := policy.Prepare(path)
+	if err != nil {
+		return nil, err
+	}
+
+	apply()
+
+	group := server.Group("/policy", middleware...)
+	group.POST("/evaluate", policy.Evaluate)
+
+	return policy, nil
+}
+
+// Prepare load rules from a file and return a function which applies them.
+func (p *Policy) Prepare(path string) (func(), error) {
+	if path == "" {
+		return func() { p.current.Store(nil) }, nil
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read policy file %s", path)
+	}
+
+	var policyDocument document
+	err = yaml.Unmarshal(contents, &policyDocument)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse policy file %s", path)
+	}
+
+	rules, err := compile(policyDocument)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid policy file %s", path)
+	}
+
+	return func() { p.current.Store(rules) }, nil
+}
+
+// Enabled determine if a policy file has been loaded.
+func (p *Policy) Enabled() bool {
+	return p.current.Load() != nil
+}
+
+// Authorize evaluate attributes against each rule, the first rule with a matching condition decides the outcome.
+func (p *Policy) Authorize(attributes Attributes) (Decision, error) {
+	if attributes.Time.IsZero() {
+		attributes.Time = time.Now()
+	}
+
+	current := p.current.Load()
+	if current == nil {
+		return Decision{Allowed: true}, nil
+	}
+
+	activation := attributes.activation()
+
+	for _, policyRule := range current.rules {
+		result, _, err := policyRule.program.Eval(activation)
+		if err != nil {
+			return Decision{Allowed: false, Rule: policyRule.name}, errors.Wrap(err, "unable to evaluate policy rule %s", policyRule.name)
+		}
+
+		matched, ok := result.Value().(bool)
+		if !ok {
+			return Decision{Allowed: false, Rule: policyRule.name}, errors.New("policy rule %s did not evaluate to a boolean", policyRule.name)
+		}
+
+		if matched {
+			return Decision{Allowed: policyRule.effect == Allow, Message: policyRule.message, Rule: policyRule.name}, nil
+		}
+	}
+
+	return Decision{Allowed: current.fallback == Allow}, nil
+}
+
+// activation convert attributes to variables available to rule conditions.
+func (a Attributes) activation() map[string]any {
+	roles := a.Client.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+
+	tags := a.VM.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	return map[string]any{
+		"action": a.Action,
+		"client": map[string]any{
+			"method": a.Client.Method,
+			"name":   a.Client.Name,
+			"roles":  roles,
+		},
+		"source_ip": a.SourceIP,
+		"time":      a.Time,
+		"vm": map[string]any{
+			"folder": a.VM.Folder,
+			"id":     a.VM.ID,
+			"name":   a.VM.Name,
+			"tags":   tags,
+		},
+	}
+}
+
+// compile each rule within a policy document.
+func compile(policyDocument document) (*ruleset, error) {
+	environment, err := cel.NewEnv(
+		cel.Variable("action", cel.StringType),
+		cel.Variable("client", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("source_ip", cel.StringType),
+		cel.Variable("time", cel.TimestampType),
+		cel.Variable("vm", cel.MapType(cel.StringType, cel.DynType)),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create policy environment")
+	}
+
+	fallback := strings.ToLower(strings.TrimSpace(policyDocument.Default))
+	if fallback == "" {
+		fallback = Allow
+	}
+
+	if fallback != Allow && fallback != Deny {
+		return nil, errors.New("default must be one of: %s, %s", Allow, Deny)
+	}
+
+	compiled := &ruleset{
+		fallback: fallback,
+		rules:    make([]rule, 0, len(policyDocument.Rules)),
+	}
+
+	for index, definition := range policyDocument.Rules {
+		name := strings.TrimSpace(definition.Name)
+		if name == "" {
+			return nil, errors.New("rule %d has no name", index+1)
+		}
+
+		effect := strings.ToLower(strings.TrimSpace(definition.Effect))
+		if effect != Allow && effect != Deny {
+			return nil, errors.New("rule %s effect must be one of: %s, %s", name, Allow, Deny)
+		}
+
+		ast, issues := environment.Compile(definition.Condition)
+		if issues != nil && issues.Err() != nil {
+			return nil, errors.Wrap(issues.Err(), "unable to compile rule %s", name)
+		}
+
+		if ast.OutputType() != cel.BoolType {
+			return nil, errors.New("rule %s condition must evaluate to a boolean", name)
+		}
+
+		program, err := environment.Program(ast)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to create program for rule %s", name)
+		}
+
+		compiled.rules = append(compiled.rules, rule{
+			effect:  effect,
+			message: strings.TrimSpace(definition.Message),
+			name:    name,
+			program: program,
+		})
+	}
+
+	return compiled, nil
+}
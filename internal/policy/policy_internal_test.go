@@ -0,0 +1,198 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/internal/identity"
+)
+
+// writePolicy write a policy document to a temporary file.
+func writePolicy(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+
+	return path
+}
+
+func TestPolicy_Authorize(t *testing.T) {
+	t.Parallel()
+
+	rules, err := New(writePolicy(t, `
+default: deny
+rules:
+  - name: protected
+    condition: '"protected" in vm.tags'
+    effect: deny
+    message: virtual machine is protected
+  - name: production
+    condition: 'vm.folder == "prod" && !("oncall" in client.roles)'
+    effect: deny
+  - name: business-hours
+    condition: 'action == "off" && time.getHours("UTC") < 9'
+    effect: deny
+  - name: operators
+    condition: '"operators" in client.roles'
+    effect: allow
+  - name: lab
+    condition: 'vm.folder == "lab" || vm.name.startsWith("lab-")'
+    effect: allow
+`), echo.New())
+	require.NoError(t, err)
+
+	operator := identity.Identity{Method: "oidc", Name: "alice", Roles: []string{"operators"}}
+	oncall := identity.Identity{Method: "oidc", Name: "bob", Roles: []string{"operators", "oncall"}}
+	afternoon := time.Date(2024, 1, 1, 14, 0, 0, 0, time.UTC)
+
+	testcases := map[string]struct {
+		attributes Attributes
+		expected   Decision
+	}{
+		"allowed by role": {
+			attributes: Attributes{Action: "on", Client: operator, Time: afternoon, VM: VirtualMachine{Folder: "web", Name: "web1"}},
+			expected:   Decision{Allowed: true, Rule: "operators"},
+		},
+		"denied by tag": {
+			attributes: Attributes{Action: "on", Client: oncall, Time: afternoon, VM: VirtualMachine{Name: "db1", Tags: []string{"database", "protected"}}},
+			expected:   Decision{Allowed: false, Message: "virtual machine is protected", Rule: "protected"},
+		},
+		"other tags": {
+			attributes: Attributes{Action: "on", Client: operator, Time: afternoon, VM: VirtualMachine{Name: "db1", Tags: []string{"database"}}},
+			expected:   Decision{Allowed: true, Rule: "operators"},
+		},
+		"denied by folder": {
+			attributes: Attributes{Action: "on", Client: operator, Time: afternoon, VM: VirtualMachine{Folder: "prod", Name: "app1"}},
+			expected:   Decision{Allowed: false, Rule: "production"},
+		},
+		"folder allowed for role": {
+			attributes: Attributes{Action: "on", Client: oncall, Time: afternoon, VM: VirtualMachine{Folder: "prod", Name: "app1"}},
+			expected:   Decision{Allowed: true, Rule: "operators"},
+		},
+		"denied by time": {
+			attributes: Attributes{Action: "off", Client: operator, Time: time.Date(2024, 1, 1, 3, 0, 0, 0, time.UTC), VM: VirtualMachine{Name: "web1"}},
+			expected:   Decision{Allowed: false, Rule: "business-hours"},
+		},
+		"allowed by folder": {
+			attributes: Attributes{Action: "on", Client: identity.Anonymous, Time: afternoon, VM: VirtualMachine{Folder: "lab", Name: "test1"}},
+			expected:   Decision{Allowed: true, Rule: "lab"},
+		},
+		"allowed by name": {
+			attributes: Attributes{Action: "on", Client: identity.Anonymous, Time: afternoon, VM: VirtualMachine{Name: "lab-1"}},
+			expected:   Decision{Allowed: true, Rule: "lab"},
+		},
+		"default": {
+			attributes: Attributes{Action: "on", Client: identity.Anonymous, Time: afternoon, VM: VirtualMachine{Folder: "web", Name: "web1"}},
+			expected:   Decision{Allowed: false},
+		},
+		"no roles or tags": {
+			attributes: Attributes{Action: "on", Time: afternoon},
+			expected:   Decision{Allowed: false},
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			decision, err := rules.Authorize(testcase.attributes)
+			require.NoError(t, err)
+			assert.Equal(t, testcase.expected, decision)
+		})
+	}
+}
+
+func TestPolicy_Prepare(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		contents string
+		enabled  bool
+		allowed  bool
+		err      bool
+	}{
+		"default allow": {
+			contents: `rules: []`,
+			enabled:  true,
+			allowed:  true,
+		},
+		"default deny": {
+			contents: `default: DENY`,
+			enabled:  true,
+			allowed:  false,
+		},
+		"invalid default": {
+			contents: `default: maybe`,
+			err:      true,
+		},
+		"missing name": {
+			contents: `rules: [{condition: "true", effect: allow}]`,
+			err:      true,
+		},
+		"invalid effect": {
+			contents: `rules: [{name: everything, condition: "true", effect: permit}]`,
+			err:      true,
+		},
+		"invalid condition": {
+			contents: `rules: [{name: everything, condition: "vm.", effect: allow}]`,
+			err:      true,
+		},
+		"condition isn't a boolean": {
+			contents: `rules: [{name: everything, condition: "vm.name", effect: allow}]`,
+			err:      true,
+		},
+		"unknown variable": {
+			contents: `rules: [{name: everything, condition: "host == 'a'", effect: allow}]`,
+			err:      true,
+		},
+		"invalid yaml": {
+			contents: `rules: [`,
+			err:      true,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rules := &Policy{}
+
+			apply, err := rules.Prepare(writePolicy(t, testcase.contents))
+			if testcase.err {
+				require.Error(t, err)
+				assert.False(t, rules.Enabled())
+
+				return
+			}
+
+			require.NoError(t, err)
+			apply()
+
+			assert.Equal(t, testcase.enabled, rules.Enabled())
+
+			decision, err := rules.Authorize(Attributes{Action: "on"})
+			require.NoError(t, err)
+			assert.Equal(t, testcase.allowed, decision.Allowed)
+		})
+	}
+}
+
+func TestPolicy_Disabled(t *testing.T) {
+	t.Parallel()
+
+	rules, err := New("", echo.New())
+	require.NoError(t, err)
+
+	assert.False(t, rules.Enabled())
+
+	decision, err := rules.Authorize(Attributes{Action: "off", VM: VirtualMachine{Tags: []string{"protected"}}})
+	require.NoError(t, err)
+	assert.Equal(t, Decision{Allowed: true}, decision)
+}
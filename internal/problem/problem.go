@@ -0,0 +1,165 @@
+package problem
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+// ContentType media type of problem details.
+const ContentType = "application/problem+json"
+
+// typePrefix namespace problem types are identified within.
+const typePrefix = "urn:vsphere-bridge:problem:"
+
+// kinds which have a status code, used to give errors without a kind a consistent code.
+var kinds = []errors.Kind{
+	errors.KindConflict,
+	errors.KindForbidden,
+	errors.KindNotFound,
+	errors.KindNotImplemented,
+	errors.KindTimeout,
+	errors.KindUnauthenticated,
+	errors.KindUnavailable,
+	errors.KindUpstream,
+}
+
+// Problem details of an error as described by RFC 7807.
+type Problem struct {
+	Code      string   `json:"code"`
+	Detail    string   `json:"detail,omitempty"`
+	Instance  string   `json:"instance,omitempty"`
+	RequestID string   `json:"request_id,omitempty"`
+	Status    int      `json:"status"`
+	Title     string   `json:"title"`
+	Trace     []string `json:"trace,omitempty"`
+	Type      string   `json:"type"`
+}
+
+// Handler reports errors returned by routes as problem details.
+type Handler struct {
+	debug  atomic.Bool
+	logger logging.Logger
+}
+
+// New create a new Handler.
+func New(config *configuration.Configuration, logger logging.Logger) *Handler {
+	handler := &Handler{
+		logger: logger,
+	}
+
+	handler.debug.Store(config.Debug)
+
+	return handler
+}
+
+// Attach report errors from a server as problem details and assign each request an id.
+func (h *Handler) Attach(server *echo.Echo) {
+	server.HTTPErrorHandler = h.Handle
+	server.Pre(middleware.RequestID())
+}
+
+// Handle write an error as problem details.
+func (h *Handler) Handle(err error, ctx echo.Context) {
+	problem := h.build(err, ctx)
+
+	h.logger.Error(errors.Wrap(err, "request %s failed", problem.RequestID))
+
+	if ctx.Response().Committed {
+		return
+	}
+
+	// Errors such as an open circuit breaker know when the request is worth repeating
+	var delayed interface{ RetryAfter() time.Duration }
+	if errors.As(err, &delayed) {
+		ctx.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(delayed.RetryAfter().Seconds()))))
+	}
+
+	if ctx.Request().Method == http.MethodHead {
+		_ = ctx.NoContent(problem.Status)
+
+		return
+	}
+
+	ctx.Response().Header().Set(echo.HeaderContentType, ContentType)
+	_ = ctx.JSON(problem.Status, problem)
+}
+
+// Prepare validate configuration and return a function which applies it.
+func (h *Handler) Prepare(config *configuration.Configuration) (func(), error) {
+	return func() {
+		h.debug.Store(config.Debug)
+	}, nil
+}
+
+// build problem details for an error, errors returned by echo or middleware take precedence over error kinds.
+func (h *Handler) build(err error, ctx echo.Context) Problem {
+	kind := errors.KindOf(err)
+	status := kind.Status()
+	detail := errors.Detail(err)
+
+	var httpError *echo.HTTPError
+	if errors.As(err, &httpError) {
+		kind = errors.KindUnknown
+		status = httpError.Code
+		detail = fmt.Sprintf("%v", httpError.Message)
+	}
+
+	code := kind.String()
+	if kind == errors.KindUnknown {
+		code = statusCode(status)
+	}
+
+	problem := Problem{
+		Code:      code,
+		Detail:    detail,
+		Instance:  ctx.Request().URL.Path,
+		RequestID: ctx.Response().Header().Get(echo.HeaderXRequestID),
+		Status:    status,
+		Title:     http.StatusText(status),
+		Type:      typePrefix + code,
+	}
+
+	if h.debug.Load() {
+		problem.Trace = trace(err)
+	}
+
+	return problem
+}
+
+// statusCode code for a status, using the code of a kind with the same status if there is one.
+func statusCode(status int) string {
+	for _, kind := range kinds {
+		if kind.Status() == status {
+			return kind.String()
+		}
+	}
+
+	text := http.StatusText(status)
+	if text == "" {
+		return errors.KindUnknown.String()
+	}
+
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
+
+// trace of each message in an error chain.
+func trace(err error) []string {
+	var traced errors.Error
+	if !errors.As(err, &traced) {
+		return []string{err.Error()}
+	}
+
+	return strings.Split(traced.Trace(), "\n- ")[1:]
+}
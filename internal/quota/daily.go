@@ -0,0 +1,97 @@
+package quota
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+// daily counters which reset at midnight UTC and persist across restarts when a path is configured.
+type daily struct {
+	Counts map[string]int `json:"counts"`
+	Date   string         `json:"date"`
+	path   string
+}
+
+// load daily counters from a file, a missing file or empty path starts with no counters.
+func load(path string) (*daily, error) {
+	counters := &daily{
+		Counts: make(map[string]int),
+		path:   path,
+	}
+
+	if path == "" {
+		return counters, nil
+	}
+
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return counters, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read quota file %s", path)
+	}
+
+	err = json.Unmarshal(contents, counters)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse quota file %s", path)
+	}
+
+	if counters.Counts == nil {
+		counters.Counts = make(map[string]int)
+	}
+
+	return counters, nil
+}
+
+// rollover clear counters from a previous day.
+func (d *daily) rollover(now time.Time) {
+	today := now.UTC().Format(time.DateOnly)
+	if d.Date == today {
+		return
+	}
+
+	d.Counts = make(map[string]int)
+	d.Date = today
+}
+
+// save counters to the file, written to a temporary file first so a crash can't leave a partial file.
+func (d *daily) save() error {
+	if d.path == "" {
+		return nil
+	}
+
+	contents, err := json.Marshal(d)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal quota counters")
+	}
+
+	temporary, err := os.CreateTemp(filepath.Dir(d.path), filepath.Base(d.path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "unable to create temporary quota file")
+	}
+
+	defer os.Remove(temporary.Name())
+
+	_, err = temporary.Write(contents)
+	if err == nil {
+		err = temporary.Close()
+	} else {
+		_ = temporary.Close()
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "unable to write temporary quota file")
+	}
+
+	err = os.Rename(temporary.Name(), d.path)
+	if err != nil {
+		return errors.Wrap(err, "unable to replace quota file %s", d.path)
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+package quota
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/identity"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+// Quota limits how often power actions can be performed by each client and against each virtual machine.
+type Quota struct {
+	daily   *daily
+	logger  logging.Logger
+	mutex   sync.Mutex
+	now     func() time.Time
+	rules   atomic.Pointer[[]rule]
+	windows map[string][]time.Time
+}
+
+// New create a new Quota instance and load persisted daily counters.
+func New(config *configuration.Configuration, logger logging.Logger) (*Quota, error) {
+	quota := &Quota{
+		daily:   &daily{},
+		logger:  logger,
+		now:     time.Now,
+		windows: make(map[string][]time.Time),
+	}
+
+	apply, err := quota.Prepare(config)
+	if err != nil {
+		return nil, err
+	}
+
+	apply()
+
+	return quota, nil
+}
+
+// Prepare parse limits and load daily counters from a configuration and return a function which applies them.
+func (q *Quota) Prepare(config *configuration.Configuration) (func(), error) {
+	rules, err := parseRules(config.PowerLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	counters, err := load(config.QuotaFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+
+		// Keep in-memory counters when the file hasn't changed, they may be newer than the file
+		if q.daily.path != counters.path {
+			q.daily = counters
+		}
+
+		q.rules.Store(&rules)
+	}, nil
+}
+
+// subject key and the rules which apply to it.
+type subject struct {
+	key   string
+	rules []rule
+	scope string
+}
+
+// Client key a client is counted against. Only identities verified by the bridge are trusted, clients which share an
+// identity such as webhook senders, or which haven't been verified such as basic authentication passthrough, are
+// counted by address and each pre-signed url is counted separately.
+func Client(ctx echo.Context) string {
+	client := identity.FromContext(ctx)
+
+	switch client.Method {
+	case "certificate", "oidc":
+		return client.Name
+	case "signature":
+		return fmt.Sprintf("%s %.16s", client.Name, ctx.QueryParam("signature"))
+	case "webhook":
+		return fmt.Sprintf("%s %s", client.Name, ctx.RealIP())
+	}
+
+	return ctx.RealIP()
+}
+
+// Take count an action by a client against a virtual machine, returning an error if any limit for either has been
+// reached. Actions should only be counted once a client has been authorized to perform them.
+func (q *Quota) Take(ctx echo.Context, action string, client string, vm string) error {
+	subjects := []subject{
+		{key: client, rules: q.applicable(ScopeClient, client, action), scope: ScopeClient},
+		{key: vm, rules: q.applicable(ScopeVM, vm, action), scope: ScopeVM},
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	now := q.now()
+
+	// Check every limit before counting so a rejected request doesn't use up other limits
+	for _, current := range subjects {
+		for _, limit := range current.rules {
+			remaining := q.remaining(limit, current.key, now)
+			if remaining <= 0 {
+				continue
+			}
+
+			q.logger.Warn("%s %s exceeded power limit %s for %s", current.scope, current.key, limit.text, action)
+
+			seconds := int(remaining.Round(time.Second).Seconds())
+			ctx.Response().Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+
+			return echo.NewHTTPError(http.StatusTooManyRequests, fmt.Sprintf("%s %s has reached the limit of %s, try again in %s", current.scope, current.key, limit.text, remaining.Round(time.Second)))
+		}
+	}
+
+	persist := false
+
+	for _, current := range subjects {
+		persist = q.count(current, now) || persist
+	}
+
+	if persist {
+		err := q.daily.save()
+		if err != nil {
+			q.logger.Error(errors.Wrap(err, "unable to persist daily quota counters"))
+		}
+	}
+
+	return nil
+}
+
+// count an action against a subject, returning whether a daily counter changed.
+func (q *Quota) count(current subject, now time.Time) bool {
+	persist := false
+
+	for _, limit := range current.rules {
+		id := limit.text + "|" + current.key
+		if limit.daily {
+			q.daily.Counts[id]++
+			persist = true
+
+			continue
+		}
+
+		q.windows[id] = append(q.windows[id], now)
+	}
+
+	return persist
+}
+
+// applicable rules for a key and action, rules which name the key replace rules which apply to every key.
+func (q *Quota) applicable(scope string, key string, action string) []rule {
+	named := make([]rule, 0)
+	generic := make([]rule, 0)
+
+	for _, limit := range *q.rules.Load() {
+		if !limit.matches(scope, action) {
+			continue
+		}
+
+		switch limit.name {
+		case "":
+			generic = append(generic, limit)
+		case key:
+			named = append(named, limit)
+		}
+	}
+
+	if len(named) > 0 {
+		return named
+	}
+
+	return generic
+}
+
+// remaining time until a limit allows another action for a key, zero if the action is allowed now.
+func (q *Quota) remaining(limit rule, key string, now time.Time) time.Duration {
+	id := limit.text + "|" + key
+
+	if limit.daily {
+		q.daily.rollover(now)
+		if q.daily.Counts[id] < limit.count {
+			return 0
+		}
+
+		year, month, day := now.UTC().Date()
+
+		return time.Date(year, month, day+1, 0, 0, 0, 0, time.UTC).Sub(now)
+	}
+
+	// Drop actions which have moved out of the window
+	times := q.windows[id]
+	for len(times) > 0 && now.Sub(times[0]) >= limit.window {
+		times = times[1:]
+	}
+
+	if len(times) == 0 {
+		delete(q.windows, id)
+
+		return 0
+	}
+
+	q.windows[id] = times
+	if len(times) < limit.count {
+		return 0
+	}
+
+	return times[len(times)-limit.count].Add(limit.window).Sub(now)
+}
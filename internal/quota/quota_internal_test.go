@@ -0,0 +1,263 @@
+package quota
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/identity"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+// take a single action counted against a quota.
+type take struct {
+	advance    time.Duration
+	action     string
+	client     string
+	vm         string
+	allowed    bool
+	retryAfter string
+}
+
+// newTestQuota create a quota with a clock which only moves when the test advances it.
+func newTestQuota(t *testing.T, limits string, file string, now *time.Time) *Quota {
+	t.Helper()
+
+	logger, err := logging.New(logging.Error, io.Discard, 0)
+	require.NoError(t, err)
+
+	limit, err := New(&configuration.Configuration{PowerLimits: limits, QuotaFile: file}, logger)
+	require.NoError(t, err)
+
+	limit.now = func() time.Time { return *now }
+
+	return limit
+}
+
+func TestQuota_Take(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		limits string
+		start  time.Time
+		takes  []take
+	}{
+		"no limits": {
+			limits: "",
+			takes: []take{
+				{action: "reset", client: "ci", vm: "vm1", allowed: true},
+				{action: "reset", client: "ci", vm: "vm1", allowed: true},
+			},
+		},
+		"within window": {
+			limits: "client:reset=2/1h",
+			takes: []take{
+				{action: "reset", client: "ci", vm: "vm1", allowed: true},
+				{advance: 10 * time.Minute, action: "reset", client: "ci", vm: "vm2", allowed: true},
+				{advance: 10 * time.Minute, action: "reset", client: "ci", vm: "vm3", retryAfter: "2400"},
+			},
+		},
+		"sliding window": {
+			limits: "client:reset=2/1h",
+			takes: []take{
+				{action: "reset", client: "ci", vm: "vm1", allowed: true},
+				{advance: 30 * time.Minute, action: "reset", client: "ci", vm: "vm1", allowed: true},
+				{advance: 30 * time.Minute, action: "reset", client: "ci", vm: "vm1", allowed: true},
+				{advance: 29 * time.Minute, action: "reset", client: "ci", vm: "vm1", retryAfter: "60"},
+				{advance: time.Minute, action: "reset", client: "ci", vm: "vm1", allowed: true},
+			},
+		},
+		"rejected actions aren't counted": {
+			limits: "client:reset=1/1h",
+			takes: []take{
+				{action: "reset", client: "ci", vm: "vm1", allowed: true},
+				{advance: 59 * time.Minute, action: "reset", client: "ci", vm: "vm1", retryAfter: "60"},
+				{advance: time.Minute, action: "reset", client: "ci", vm: "vm1", allowed: true},
+			},
+		},
+		"retry after is at least a second": {
+			limits: "client:reset=1/1h",
+			takes: []take{
+				{action: "reset", client: "ci", vm: "vm1", allowed: true},
+				{advance: time.Hour - 100*time.Millisecond, action: "reset", client: "ci", vm: "vm1", retryAfter: "1"},
+			},
+		},
+		"per client": {
+			limits: "client:*=1/1h",
+			takes: []take{
+				{action: "on", client: "ci", vm: "vm1", allowed: true},
+				{action: "off", client: "ci", vm: "vm2", retryAfter: "3600"},
+				{action: "on", client: "ops", vm: "vm1", allowed: true},
+			},
+		},
+		"per vm": {
+			limits: "vm:cycle=1/5m",
+			takes: []take{
+				{action: "cycle", client: "ci", vm: "vm1", allowed: true},
+				{advance: time.Minute, action: "cycle", client: "ops", vm: "vm1", retryAfter: "240"},
+				{action: "cycle", client: "ci", vm: "vm2", allowed: true},
+				{action: "reset", client: "ci", vm: "vm1", allowed: true},
+			},
+		},
+		"named rule replaces generic rules": {
+			limits: "client:*=1/1h,client(ci):*=3/1h",
+			takes: []take{
+				{action: "on", client: "ci", vm: "vm1", allowed: true},
+				{action: "on", client: "ci", vm: "vm1", allowed: true},
+				{action: "on", client: "ci", vm: "vm1", allowed: true},
+				{action: "on", client: "ci", vm: "vm1", retryAfter: "3600"},
+				{action: "on", client: "ops", vm: "vm1", allowed: true},
+				{action: "on", client: "ops", vm: "vm1", retryAfter: "3600"},
+			},
+		},
+		"vm limit rejection doesn't use client limit": {
+			limits: "client:*=2/1h,vm:*=1/1h",
+			takes: []take{
+				{action: "on", client: "ci", vm: "vm1", allowed: true},
+				{action: "on", client: "ci", vm: "vm1", retryAfter: "3600"},
+				{action: "on", client: "ci", vm: "vm2", allowed: true},
+				{action: "on", client: "ci", vm: "vm3", retryAfter: "3600"},
+			},
+		},
+		"daily resets at midnight utc": {
+			limits: "client:*=2/day",
+			start:  time.Date(2024, 1, 1, 22, 0, 0, 0, time.FixedZone("AEST", 10*60*60)),
+			takes: []take{
+				{action: "on", client: "ci", vm: "vm1", allowed: true},
+				{action: "off", client: "ci", vm: "vm1", allowed: true},
+				{advance: 11*time.Hour + 30*time.Minute, action: "on", client: "ci", vm: "vm1", retryAfter: "1800"},
+				{advance: 30 * time.Minute, action: "on", client: "ci", vm: "vm1", allowed: true},
+			},
+		},
+		"daily per vm": {
+			limits: "vm(db1):reset=1/day",
+			start:  time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+			takes: []take{
+				{action: "reset", client: "ci", vm: "db1", allowed: true},
+				{action: "reset", client: "ops", vm: "db1", retryAfter: "43200"},
+				{action: "reset", client: "ops", vm: "db2", allowed: true},
+				{advance: 12 * time.Hour, action: "reset", client: "ops", vm: "db1", allowed: true},
+			},
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			now := testcase.start
+			if now.IsZero() {
+				now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			}
+
+			limit := newTestQuota(t, testcase.limits, "", &now)
+
+			for index, step := range testcase.takes {
+				now = now.Add(step.advance)
+
+				recorder := httptest.NewRecorder()
+				ctx := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), recorder)
+
+				err := limit.Take(ctx, step.action, step.client, step.vm)
+				if step.allowed {
+					require.NoError(t, err, "take %d", index)
+
+					continue
+				}
+
+				var httpError *echo.HTTPError
+				require.ErrorAs(t, err, &httpError, "take %d", index)
+				assert.Equal(t, http.StatusTooManyRequests, httpError.Code, "take %d", index)
+				assert.Equal(t, step.retryAfter, recorder.Header().Get("Retry-After"), "take %d", index)
+			}
+		})
+	}
+}
+
+func TestQuota_Persist(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "quota.json")
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	ctx := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+
+	limit := newTestQuota(t, "client:*=2/day,client:*=1/1h", file, &now)
+	require.NoError(t, limit.Take(ctx, "on", "ci", "vm1"))
+
+	// Daily counters survive a restart, sliding windows don't
+	now = now.Add(time.Minute)
+	restarted := newTestQuota(t, "client:*=2/day,client:*=1/1h", file, &now)
+	require.NoError(t, restarted.Take(ctx, "on", "ci", "vm1"))
+	require.Error(t, restarted.Take(ctx, "on", "ci", "vm1"))
+
+	// Counters from a previous day are discarded
+	now = now.Add(24 * time.Hour)
+	tomorrow := newTestQuota(t, "client:*=2/day", file, &now)
+	require.NoError(t, tomorrow.Take(ctx, "on", "ci", "vm1"))
+	require.NoError(t, tomorrow.Take(ctx, "on", "ci", "vm1"))
+	require.Error(t, tomorrow.Take(ctx, "on", "ci", "vm1"))
+}
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		client   identity.Identity
+		target   string
+		expected string
+	}{
+		"oidc": {
+			client:   identity.Identity{Method: "oidc", Name: "ci"},
+			expected: "ci",
+		},
+		"certificate": {
+			client:   identity.Identity{Method: "certificate", Name: "alertmanager"},
+			expected: "alertmanager",
+		},
+		"webhook": {
+			client:   identity.Identity{Method: "webhook", Name: "webhook"},
+			expected: "webhook 192.0.2.1",
+		},
+		"pre-signed url": {
+			client:   identity.Identity{Method: "signature", Name: "pre-signed url"},
+			target:   "/?signature=0123456789abcdef0123456789abcdef",
+			expected: "pre-signed url 0123456789abcdef",
+		},
+		"basic": {
+			client:   identity.Identity{Method: "basic", Name: "someone"},
+			expected: "192.0.2.1",
+		},
+		"anonymous": {
+			client:   identity.Anonymous,
+			expected: "192.0.2.1",
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			target := testcase.target
+			if target == "" {
+				target = "/"
+			}
+
+			request := httptest.NewRequest(http.MethodPost, target, nil)
+			request.RemoteAddr = "192.0.2.1:5000"
+
+			ctx := echo.New().NewContext(request, httptest.NewRecorder())
+			identity.Set(ctx, testcase.client)
+
+			assert.Equal(t, testcase.expected, Client(ctx))
+		})
+	}
+}
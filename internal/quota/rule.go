@@ -0,0 +1,115 @@
+package quota
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+const (
+	// ScopeClient limits count actions for each client.
+	ScopeClient = "client"
+
+	// ScopeVM limits count actions against each virtual machine.
+	ScopeVM = "vm"
+)
+
+// actions which can be limited.
+var actions = map[string]bool{"*": true, "cycle": true, "off": true, "on": true, "reset": true, "suspend": true}
+
+// rule limit on the number of times an action can be performed within a window, or within a UTC day.
+type rule struct {
+	action string
+	count  int
+	daily  bool
+	name   string
+	scope  string
+	text   string
+	window time.Duration
+}
+
+// parseRules parse comma separated rules in the form scope[(name)]:action=count/window, e.g. client:reset=10/1h,
+// vm:cycle=1/5m or client(ci-bot):*=500/day.
+func parseRules(value string) ([]rule, error) {
+	rules := make([]rule, 0)
+
+	for _, text := range strings.Split(value, ",") {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+
+		parsed, err := parseRule(text)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid power limit %s", text)
+		}
+
+		rules = append(rules, parsed)
+	}
+
+	return rules, nil
+}
+
+// parseRule parse a single rule.
+func parseRule(text string) (rule, error) {
+	target, limit, ok := strings.Cut(text, "=")
+	if !ok {
+		return rule{}, errors.New("expected scope:action=count/window")
+	}
+
+	scope, action, ok := strings.Cut(target, ":")
+	if !ok {
+		return rule{}, errors.New("expected scope:action=count/window")
+	}
+
+	parsed := rule{action: action, scope: scope, text: text}
+
+	if open := strings.Index(scope, "("); open >= 0 && strings.HasSuffix(scope, ")") {
+		parsed.name = scope[open+1 : len(scope)-1]
+		parsed.scope = scope[:open]
+
+		if parsed.name == "" {
+			return rule{}, errors.New("name must not be empty")
+		}
+	}
+
+	if parsed.scope != ScopeClient && parsed.scope != ScopeVM {
+		return rule{}, errors.New("scope must be one of: %s, %s", ScopeClient, ScopeVM)
+	}
+
+	if !actions[parsed.action] {
+		return rule{}, errors.New("unknown action %s", parsed.action)
+	}
+
+	count, window, ok := strings.Cut(limit, "/")
+	if !ok {
+		return rule{}, errors.New("expected count/window")
+	}
+
+	var err error
+
+	parsed.count, err = strconv.Atoi(count)
+	if err != nil || parsed.count < 1 {
+		return rule{}, errors.New("count must be a positive number")
+	}
+
+	if window == "day" {
+		parsed.daily = true
+
+		return parsed, nil
+	}
+
+	parsed.window, err = time.ParseDuration(window)
+	if err != nil || parsed.window <= 0 {
+		return rule{}, errors.New("window must be a positive duration or day")
+	}
+
+	return parsed, nil
+}
+
+// matches determine if a rule applies to an action in a scope.
+func (r rule) matches(scope string, action string) bool {
+	return r.scope == scope && (r.action == "*" || r.action == action)
+}
@@ -0,0 +1,133 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+// Prefix prefix which identifies an encrypted configuration value.
+const Prefix = "age:"
+
+// Decrypt age encrypted data, data may be binary or armored.
+func Decrypt(data []byte, identities []age.Identity) ([]byte, error) {
+	var reader io.Reader = bytes.NewReader(data)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(armor.Header)) {
+		reader = armor.NewReader(bytes.NewReader(bytes.TrimSpace(data)))
+	}
+
+	decrypted, err := age.Decrypt(reader, identities...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decrypt secret")
+	}
+
+	plaintext, err := io.ReadAll(decrypted)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read decrypted secret")
+	}
+
+	return plaintext, nil
+}
+
+// DecryptValue decrypt an encrypted configuration value.
+func DecryptValue(value string, identities []age.Identity) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(value), Prefix))
+	if err != nil {
+		return "", errors.Wrap(err, "encrypted value is not base64 encoded")
+	}
+
+	plaintext, err := Decrypt(data, identities)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// EncryptValue encrypt a configuration value for one or more recipients.
+func EncryptValue(plaintext string, recipients []age.Recipient) (string, error) {
+	var buffer bytes.Buffer
+
+	writer, err := age.Encrypt(&buffer, recipients...)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to encrypt value")
+	}
+
+	_, err = io.WriteString(writer, plaintext)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to encrypt value")
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return "", errors.Wrap(err, "unable to encrypt value")
+	}
+
+	return Prefix + base64.StdEncoding.EncodeToString(buffer.Bytes()), nil
+}
+
+// Encrypted determine if a configuration value is encrypted.
+func Encrypted(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(value), Prefix)
+}
+
+// Identities load age identities from a key, or a file containing keys if no key is set.
+func Identities(key string, keyFile string) ([]age.Identity, error) {
+	if key == "" && keyFile != "" {
+		contents, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read key file %s", keyFile)
+		}
+
+		key = string(contents)
+	}
+
+	if strings.TrimSpace(key) == "" {
+		return nil, errors.New("a key or key file is required to decrypt secrets")
+	}
+
+	identities, err := age.ParseIdentities(strings.NewReader(key))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse key")
+	}
+
+	return identities, nil
+}
+
+// Recipients parse age recipients, if none are provided recipients are derived from identities.
+func Recipients(recipients []string, identities []age.Identity) ([]age.Recipient, error) {
+	parsed := make([]age.Recipient, 0)
+
+	for _, recipient := range recipients {
+		publicKey, err := age.ParseX25519Recipient(strings.TrimSpace(recipient))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid recipient %s", recipient)
+		}
+
+		parsed = append(parsed, publicKey)
+	}
+
+	if len(parsed) > 0 {
+		return parsed, nil
+	}
+
+	for _, identity := range identities {
+		privateKey, ok := identity.(*age.X25519Identity)
+		if ok {
+			parsed = append(parsed, privateKey.Recipient())
+		}
+	}
+
+	if len(parsed) == 0 {
+		return nil, errors.New("at least one recipient or key is required to encrypt secrets")
+	}
+
+	return parsed, nil
+}
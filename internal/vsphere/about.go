@@ -0,0 +1,242 @@
+package vsphere
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+const (
+	// CapabilityAPI the /api endpoints used for virtual machines and power actions.
+	CapabilityAPI = "api"
+
+	// CapabilityTagging the /api tagging endpoints used to find tags attached to virtual machines.
+	CapabilityTagging = "tagging"
+
+	// SourceAppliance version read from the appliance api.
+	SourceAppliance = "appliance"
+
+	// SourceSOAP version read from the soap service content.
+	SourceSOAP = "soap"
+)
+
+// capabilities minimum vsphere version which supports each capability.
+var capabilities = map[string]string{
+	CapabilityAPI:     "7.0.2",
+	CapabilityTagging: "7.0.2",
+}
+
+// retrieveServiceContent soap request for the service content, which doesn't require a session.
+const retrieveServiceContent = `<?xml version="1.0" encoding="UTF-8"?>
+<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:vim25="urn:vim25">
+<soapenv:Body><vim25:RetrieveServiceContent><vim25:_this type="ServiceInstance">ServiceInstance</vim25:_this></vim25:RetrieveServiceContent></soapenv:Body>
+</soapenv:Envelope>`
+
+// About version and capabilities of a vsphere target.
+type About struct {
+	Build        string          `json:"build,omitempty"`
+	Capabilities map[string]bool `json:"capabilities"`
+	Checked      time.Time       `json:"checked"`
+	Error        string          `json:"error,omitempty"`
+	Product      string          `json:"product,omitempty"`
+	Source       string          `json:"source,omitempty"`
+	Target       string          `json:"target"`
+	Version      string          `json:"version,omitempty"`
+}
+
+// serviceContent soap representation of the service content.
+type serviceContent struct {
+	About struct {
+		Build    string `xml:"build"`
+		FullName string `xml:"fullName"`
+		Name     string `xml:"name"`
+		Version  string `xml:"version"`
+	} `xml:"Body>RetrieveServiceContentResponse>returnval>about"`
+}
+
+// About get the version and capabilities of the target, discovering them if they aren't known. Failed discoveries are
+// retried once the health check interval has passed.
+func (v *Vsphere) About(ctx context.Context) About {
+	v.discovery.Lock()
+	defer v.discovery.Unlock()
+
+	config := v.config.Load()
+	if v.about != nil && v.about.Target == config.Server.Host && (v.about.Version != "" || time.Since(v.about.Checked) < config.HealthCheckInterval) {
+		return *v.about
+	}
+
+	about := v.discover(ctx, config)
+	v.about = &about
+
+	if about.Error != "" {
+		v.logger.Warn("unable to discover version of %s: %s", about.Target, about.Error)
+	} else {
+		v.logger.Info("discovered %s on %s", about, about.Target)
+	}
+
+	return about
+}
+
+// Require return an error if the target is known not to support a capability, support is assumed if the version
+// couldn't be discovered.
+func (v *Vsphere) Require(ctx context.Context, capability string) error {
+	about := v.About(ctx)
+	if about.Version == "" || about.Capabilities[capability] {
+		return nil
+	}
+
+	return errors.NewKind(errors.KindNotImplemented, "%s isn't supported by vsphere %s on %s, version %s or later is required", capability, about.Version, about.Target, capabilities[capability])
+}
+
+// forget discovered versions so they are discovered again, e.g. after vsphere has been upgraded.
+func (v *Vsphere) forget() {
+	v.discovery.Lock()
+	defer v.discovery.Unlock()
+
+	v.about = nil
+}
+
+// discover read the version of the target from the appliance api using configured credentials, falling back to the
+// soap service content for older versions or when no credentials are configured.
+func (v *Vsphere) discover(ctx context.Context, config *configuration.Configuration) About {
+	about := About{Capabilities: make(map[string]bool), Checked: time.Now(), Target: config.Server.Host}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	var failures []error
+
+	if credentials := v.credentials.Get(); credentials.Username != "" && credentials.Password != "" {
+		err := v.discoverAppliance(ctx, credentials.Username, credentials.Password, &about)
+		if err != nil {
+			failures = append(failures, errors.Wrap(err, "unable to read appliance version"))
+		}
+	}
+
+	if about.Version == "" {
+		err := v.discoverSOAP(ctx, config, &about)
+		if err != nil {
+			failures = append(failures, errors.Wrap(err, "unable to read soap service content"))
+		}
+	}
+
+	if about.Version == "" {
+		about.Error = errors.Join(failures...).Error()
+
+		return about
+	}
+
+	for capability, minimum := range capabilities {
+		about.Capabilities[capability] = compareVersions(about.Version, minimum) >= 0
+	}
+
+	return about
+}
+
+// discoverAppliance read the version from the appliance api using a session which isn't shared with requests.
+func (v *Vsphere) discoverAppliance(ctx context.Context, username string, password string, about *About) error {
+	token, err := v.createSession(ctx, username, password)
+	if err != nil {
+		return err
+	}
+
+	// Discovery sessions are never reused
+	defer func() {
+		_, _ = v.send(ctx, http.MethodDelete, "/session", nil, header{key: "vmware-api-session-id", value: token})
+	}()
+
+	applianceVersion, err := v.applianceVersion(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	about.Build = applianceVersion.Build
+	about.Product = applianceVersion.Product
+	about.Source = SourceAppliance
+	about.Version = applianceVersion.Version
+
+	return nil
+}
+
+// discoverSOAP read the version from the soap service content, which is available on every version without a session.
+func (v *Vsphere) discoverSOAP(ctx context.Context, config *configuration.Configuration, about *About) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, config.Server.String()+"/sdk", strings.NewReader(retrieveServiceContent))
+	if err != nil {
+		return errors.Wrap(err, "unable to create soap request")
+	}
+
+	request.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	request.Header.Set("SOAPAction", "urn:vim25/6.0")
+
+	response, err := v.client.Load().Do(request)
+	if err != nil {
+		return errors.WrapKind(err, errors.KindUpstream, "error sending soap request")
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return errors.WrapKind(err, errors.KindUpstream, "error reading soap response")
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return errors.NewKind(errors.KindUpstream, "soap request returned %d", response.StatusCode)
+	}
+
+	var content serviceContent
+	err = xml.NewDecoder(bytes.NewReader(body)).Decode(&content)
+	if err != nil {
+		return errors.Wrap(err, "unable to parse soap response")
+	}
+
+	if content.About.Version == "" {
+		return errors.New("soap response didn't include a version")
+	}
+
+	about.Build = content.About.Build
+	about.Product = content.About.Name
+	about.Source = SourceSOAP
+	about.Version = content.About.Version
+
+	return nil
+}
+
+// compareVersions compare dotted versions numerically, returning -1, 0 or 1. Missing segments are treated as zero.
+func compareVersions(left string, right string) int {
+	leftSegments := strings.Split(left, ".")
+	rightSegments := strings.Split(right, ".")
+
+	for index := range max(len(leftSegments), len(rightSegments)) {
+		var leftNumber, rightNumber int
+		if index < len(leftSegments) {
+			leftNumber, _ = strconv.Atoi(leftSegments[index])
+		}
+
+		if index < len(rightSegments) {
+			rightNumber, _ = strconv.Atoi(rightSegments[index])
+		}
+
+		switch {
+		case leftNumber < rightNumber:
+			return -1
+		case leftNumber > rightNumber:
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// String summary of the discovered version.
+func (a About) String() string {
+	return fmt.Sprintf("%s %s build %s", a.Product, a.Version, a.Build)
+}
@@ -1,49 +1,191 @@
 package vsphere
 
 import (
+	"context"
 	"encoding/base64"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 
 	"github.com/sjdaws/vsphere-bridge/pkg/errors"
 )
 
-// authenticate to the vsphere API.
-func (v *Vsphere) authenticate(ctx echo.Context) error {
-	// Only create an auth header if one doesn't exist
+// session vsphere api session.
+type session struct {
+	credentials string
+	expired     bool
+	passthrough bool
+	retired     bool
+	token       string
+	users       int
+}
+
+// open a session for a request. Requests using configured credentials share a session, requests passing through their
+// own credentials get a session of their own so they are never run as someone else. The session must be closed once
+// the request completes.
+func (v *Vsphere) open(ctx echo.Context) (*session, error) {
 	credentials := strings.TrimSpace(ctx.Request().Header.Get("Authorization"))
-	if credentials == "" && v.config.Username != "" && v.config.Password != "" {
-		credentials = "Basic " + base64.StdEncoding.EncodeToString([]byte(v.config.Username+":"+v.config.Password))
-	}
+	if credentials != "" {
+		token, err := v.authenticate(ctx, credentials, true)
+		if err != nil {
+			return nil, err
+		}
 
-	// If no credentials, there isn't much we can do
-	if credentials == "" {
-		return errors.New("one of: vsphere username and password, basic authorization header are required")
+		return &session{credentials: credentials, passthrough: true, token: token, users: 1}, nil
 	}
 
-	response, err := v.request(http.MethodPost, "/session", nil, header{key: "Authorization", value: credentials})
-	if err != nil {
-		return errors.Wrap(err, "unable to fetch session token")
+	v.sessions.Lock()
+	defer v.sessions.Unlock()
+
+	// Concurrent requests wait for a single session to be created rather than creating one each
+	if v.service == nil {
+		configured := v.credentials.Get()
+		if configured.Username == "" || configured.Password == "" {
+			return nil, errors.NewKind(errors.KindUnauthenticated, "one of: vsphere username and password, basic authorization header are required")
+		}
+
+		credentials = "Basic " + base64.StdEncoding.EncodeToString([]byte(configured.Username+":"+configured.Password))
+
+		token, err := v.authenticate(ctx, credentials, false)
+		if err != nil {
+			return nil, err
+		}
+
+		v.service = &session{token: token}
 	}
 
-	// Body will contain api token, but it is also quoted for some wierd reason so trim off quotes
-	v.token = strings.Trim(string(response), `"`)
+	v.service.users++
 
-	return nil
+	return v.service, nil
 }
 
-// logout from the vsphere API.
-func (v *Vsphere) logout(ctx echo.Context) {
-	if v.token == "" {
+// close release a session, logging out once it is no longer in use and won't be reused.
+func (v *Vsphere) close(current *session) {
+	if current.passthrough {
+		v.logout(current.token)
+
 		return
 	}
 
-	_, err := v.Request(ctx, http.MethodDelete, "/session", nil)
+	v.sessions.Lock()
+	current.users--
+	done := current.retired && !current.expired && current.users == 0
+	v.sessions.Unlock()
+
+	if done {
+		v.logout(current.token)
+	}
+}
+
+// renew replace an expired session, the expired session is released.
+func (v *Vsphere) renew(ctx echo.Context, expired *session) (*session, error) {
+	if expired.passthrough {
+		token, err := v.authenticate(ctx, expired.credentials, true)
+		if err != nil {
+			return nil, err
+		}
+
+		return &session{credentials: expired.credentials, passthrough: true, token: token, users: 1}, nil
+	}
+
+	v.sessions.Lock()
+	if v.service == expired {
+		v.service = nil
+	}
+
+	expired.expired = true
+	expired.retired = true
+	expired.users--
+	v.sessions.Unlock()
+
+	return v.open(ctx)
+}
+
+// invalidate the shared session so the next request authenticates with current credentials, requests already using
+// the session finish with it before it is logged out.
+func (v *Vsphere) invalidate() {
+	v.sessions.Lock()
+	current := v.service
+	v.service = nil
+
+	idle := false
+	if current != nil {
+		current.retired = true
+		idle = !current.expired && current.users == 0
+	}
+	v.sessions.Unlock()
+
+	if idle {
+		v.logout(current.token)
+	}
+}
+
+// Close log out of the shared session.
+func (v *Vsphere) Close() {
+	v.invalidate()
+}
+
+// authenticate to the vsphere API and return a session token.
+func (v *Vsphere) authenticate(ctx echo.Context, credentials string, passthrough bool) (string, error) {
+	// Stop forwarding credentials which repeatedly fail before vsphere locks out the account
+	var keys []string
+	if passthrough {
+		keys = []string{"source:" + ctx.RealIP()}
+
+		// Credentials which aren't basic authentication have no username, they must not share a single lockout key
+		username, _, ok := ctx.Request().BasicAuth()
+		if ok {
+			keys = append(keys, "username:"+username)
+		}
+
+		remaining := v.lockout.locked(keys...)
+		if remaining > 0 {
+			ctx.Response().Header().Set("Retry-After", strconv.Itoa(int(remaining.Round(time.Second).Seconds())))
+
+			return "", echo.NewHTTPError(http.StatusTooManyRequests, fmt.Sprintf("too many failed authentication attempts, try again in %s", remaining.Round(time.Second)))
+		}
+	}
+
+	// Creating a session has no side effects so it is safe to retry
+	response, err := v.retry(ctx.Request().Context(), call{
+		headers:    []header{{key: "Authorization", value: credentials}},
+		idempotent: true,
+		method:     http.MethodPost,
+		path:       "/session",
+	})
 	if err != nil {
-		v.logger.Error("unable to logout of session: %v", err)
+		if passthrough && errors.KindOf(err) == errors.KindUnauthenticated {
+			duration := v.lockout.fail(keys...)
+			if duration > 0 {
+				v.logger.Warn("locking out %s for %s after repeated authentication failures", strings.Join(keys, ", "), duration)
+			}
+		}
+
+		// Configured credentials being rejected is a bridge problem rather than a caller problem
+		if !passthrough && errors.KindOf(err) == errors.KindUnauthenticated {
+			return "", errors.WrapKind(err, errors.KindUpstream, "vsphere rejected configured credentials")
+		}
+
+		return "", errors.Wrap(err, "unable to fetch session token")
 	}
 
-	v.token = ""
+	if passthrough {
+		v.lockout.succeed(keys...)
+	}
+
+	// Body will contain api token, but it is also quoted for some wierd reason so trim off quotes
+	return strings.Trim(string(response.Body), `"`), nil
+}
+
+// logout of a session.
+func (v *Vsphere) logout(token string) {
+	// Logging out bypasses retries and the circuit breaker so sessions are released whenever vsphere is reachable
+	_, err := v.send(context.Background(), http.MethodDelete, "/session", nil, header{key: "vmware-api-session-id", value: token})
+	if err != nil {
+		v.logger.Error("unable to logout of session: %v", err)
+	}
 }
@@ -0,0 +1,195 @@
+package vsphere
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/credentials"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+// fakeServer vsphere api which tracks sessions.
+type fakeServer struct {
+	active   map[string]int
+	mutex    sync.Mutex
+	next     int
+	revoked  int
+	sessions map[string]string
+}
+
+// ServeHTTP handle session and vm requests.
+func (f *fakeServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	token := request.Header.Get("vmware-api-session-id")
+
+	switch {
+	case request.Method == http.MethodPost && request.URL.Path == "/api/session":
+		username, _, _ := request.BasicAuth()
+
+		f.mutex.Lock()
+		f.next++
+		token = fmt.Sprintf("token-%d", f.next)
+		f.sessions[token] = username
+		f.mutex.Unlock()
+
+		_, _ = fmt.Fprintf(writer, "%q", token)
+	case request.Method == http.MethodDelete && request.URL.Path == "/api/session":
+		f.mutex.Lock()
+		if f.active[token] > 0 {
+			f.revoked++
+		}
+		delete(f.sessions, token)
+		f.mutex.Unlock()
+	case request.URL.Path == "/api/vcenter/vm":
+		f.mutex.Lock()
+		username, ok := f.sessions[token]
+		f.active[token]++
+		f.mutex.Unlock()
+
+		defer func() {
+			f.mutex.Lock()
+			f.active[token]--
+			f.mutex.Unlock()
+		}()
+
+		if !ok {
+			writer.WriteHeader(http.StatusUnauthorized)
+			_, _ = io.WriteString(writer, `{"error_type":"UNAUTHENTICATED"}`)
+
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+
+		_, _ = fmt.Fprintf(writer, "%q", username)
+	default:
+		writer.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// open number of sessions which haven't been logged out.
+func (f *fakeServer) open() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return len(f.sessions)
+}
+
+// newTestVsphere create a vsphere instance backed by a fake server.
+func newTestVsphere(t *testing.T) (*Vsphere, *credentials.Store, *configuration.Configuration, *fakeServer) {
+	t.Helper()
+
+	fake := &fakeServer{active: make(map[string]int), sessions: make(map[string]string)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	target, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	config := &configuration.Configuration{
+		Password:      "password",
+		RetryAttempts: 3,
+		RetryBackoff:  time.Millisecond,
+		RetryTimeout:  5 * time.Second,
+		Server:        target,
+		Username:      "service",
+	}
+
+	logger, err := logging.New(logging.Error, io.Discard, 0)
+	require.NoError(t, err)
+
+	store, err := credentials.New(config, logger)
+	require.NoError(t, err)
+
+	api, err := New(config, store, logger)
+	require.NoError(t, err)
+
+	return api, store, config, fake
+}
+
+// newTestContext create an echo context for a request.
+func newTestContext(authorization string) echo.Context {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+
+	return echo.New().NewContext(request, httptest.NewRecorder())
+}
+
+func TestVsphere_CredentialRotation(t *testing.T) {
+	t.Parallel()
+
+	api, store, config, fake := newTestVsphere(t)
+
+	var wait sync.WaitGroup
+
+	for range 8 {
+		wait.Add(1)
+
+		go func() {
+			defer wait.Done()
+
+			for range 25 {
+				body, err := api.Request(newTestContext(""), http.MethodGet, "/vcenter/vm", nil)
+				if assert.NoError(t, err) {
+					assert.Equal(t, `"service"`, string(body))
+				}
+			}
+		}()
+	}
+
+	for index := range 20 {
+		rotated := *config
+		rotated.Password = fmt.Sprintf("password-%d", index)
+
+		apply, err := store.Prepare(&rotated)
+		require.NoError(t, err)
+
+		apply()
+		time.Sleep(time.Millisecond)
+	}
+
+	wait.Wait()
+	api.Close()
+
+	fake.mutex.Lock()
+	revoked := fake.revoked
+	fake.mutex.Unlock()
+
+	assert.Zero(t, revoked, "sessions were logged out while requests were using them")
+	assert.Zero(t, fake.open(), "sessions were left open")
+}
+
+func TestVsphere_PassthroughSession(t *testing.T) {
+	t.Parallel()
+
+	api, _, _, fake := newTestVsphere(t)
+
+	// Establish a shared session which a passthrough request must not reuse
+	_, err := api.Request(newTestContext(""), http.MethodGet, "/vcenter/vm", nil)
+	require.NoError(t, err)
+
+	authorization := "Basic " + base64.StdEncoding.EncodeToString([]byte("caller:secret"))
+
+	body, err := api.Request(newTestContext(authorization), http.MethodGet, "/vcenter/vm", nil)
+	require.NoError(t, err)
+	assert.Equal(t, `"caller"`, string(body))
+
+	// Only the shared session remains open
+	assert.Equal(t, 1, fake.open())
+
+	api.Close()
+	assert.Zero(t, fake.open())
+}
@@ -0,0 +1,179 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+const (
+	// BreakerClosed calls are sent to the target.
+	BreakerClosed = "closed"
+
+	// BreakerHalfOpen a single call is sent to the target to probe whether it has recovered.
+	BreakerHalfOpen = "half-open"
+
+	// BreakerOpen calls are rejected without being sent to the target.
+	BreakerOpen = "open"
+)
+
+// BreakerStatus state of the circuit breaker for a target.
+type BreakerStatus struct {
+	Failures int        `json:"failures"`
+	State    string     `json:"state"`
+	Until    *time.Time `json:"until,omitempty"`
+}
+
+// UnavailableError error when a call is rejected because the circuit breaker for a target is open.
+type UnavailableError struct {
+	Target string
+	Until  time.Time
+}
+
+// breakers circuit breaker for each target, which stops calls to a target after repeated failures.
+type breakers struct {
+	cooldown  time.Duration
+	entries   map[string]*circuit
+	mutex     sync.Mutex
+	threshold int
+}
+
+// circuit state of a single target.
+type circuit struct {
+	failures int
+	probing  bool
+	until    time.Time
+}
+
+// newBreakers create new circuit breakers, a threshold of zero disables the breakers.
+func newBreakers(threshold int, cooldown time.Duration) *breakers {
+	return &breakers{
+		cooldown:  cooldown,
+		entries:   make(map[string]*circuit),
+		threshold: threshold,
+	}
+}
+
+// allow determine whether a call can be sent to a target, once the cooldown expires a single call is allowed through
+// to probe the target.
+func (b *breakers) allow(target string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	entry, ok := b.entries[target]
+	if !ok || b.threshold <= 0 || entry.failures < b.threshold {
+		return nil
+	}
+
+	if time.Now().Before(entry.until) || entry.probing {
+		return UnavailableError{Target: target, Until: entry.until}
+	}
+
+	entry.probing = true
+
+	return nil
+}
+
+// configure change breaker settings, existing failures are kept.
+func (b *breakers) configure(threshold int, cooldown time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.cooldown = cooldown
+	b.threshold = threshold
+}
+
+// record the outcome of a call, a failed probe opens the breaker for another cooldown.
+func (b *breakers) record(target string, failed bool) (opened bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	entry, ok := b.entries[target]
+	if !ok {
+		entry = &circuit{}
+		b.entries[target] = entry
+	}
+
+	probe := entry.probing
+	entry.probing = false
+
+	if !failed {
+		entry.failures = 0
+		entry.until = time.Time{}
+
+		return false
+	}
+
+	entry.failures++
+
+	if b.threshold <= 0 || entry.failures < b.threshold {
+		return false
+	}
+
+	// Calls which were already in progress when the breaker opened don't extend the cooldown
+	if entry.failures > b.threshold && !probe {
+		return false
+	}
+
+	entry.until = time.Now().Add(b.cooldown)
+
+	return true
+}
+
+// status of the breaker for a target.
+func (b *breakers) status(target string) BreakerStatus {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	entry, ok := b.entries[target]
+	if !ok || b.threshold <= 0 || entry.failures < b.threshold {
+		failures := 0
+		if ok {
+			failures = entry.failures
+		}
+
+		return BreakerStatus{Failures: failures, State: BreakerClosed}
+	}
+
+	until := entry.until
+	if time.Now().Before(until) {
+		return BreakerStatus{Failures: entry.failures, State: BreakerOpen, Until: &until}
+	}
+
+	return BreakerStatus{Failures: entry.failures, State: BreakerHalfOpen}
+}
+
+// failing determine whether an error indicates the target is failing, rather than rejecting a request.
+func failing(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var apiError errors.APIError
+	if errors.As(err, &apiError) {
+		return apiError.Status >= http.StatusInternalServerError && apiError.Status != http.StatusNotImplemented
+	}
+
+	kind := errors.KindOf(err)
+
+	return kind == errors.KindTimeout || kind == errors.KindUpstream
+}
+
+// Error provides the target and when calls will be allowed again.
+func (e UnavailableError) Error() string {
+	return fmt.Sprintf("circuit breaker for %s is open until %s", e.Target, e.Until.Format(time.RFC3339))
+}
+
+// Kind classifies the error as unavailable.
+func (e UnavailableError) Kind() errors.Kind {
+	return errors.KindUnavailable
+}
+
+// RetryAfter duration until calls will be allowed again.
+func (e UnavailableError) RetryAfter() time.Duration {
+	return max(time.Until(e.Until), time.Second)
+}
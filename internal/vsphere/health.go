@@ -0,0 +1,231 @@
+package vsphere
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+)
+
+const (
+	// CheckFailed check which did not succeed.
+	CheckFailed = "failed"
+
+	// CheckOK check which succeeded.
+	CheckOK = "ok"
+
+	// CheckSkipped check which could not run.
+	CheckSkipped = "skipped"
+
+	// checkTimeout maximum duration of each check.
+	checkTimeout = 5 * time.Second
+)
+
+// Check result of a single readiness check.
+type Check struct {
+	Duration string `json:"duration"`
+	Message  string `json:"message,omitempty"`
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+}
+
+// Status readiness of a vsphere target.
+type Status struct {
+	Breaker BreakerStatus `json:"breaker"`
+	Checked time.Time     `json:"checked"`
+	Checks  []Check       `json:"checks"`
+	Ready   bool          `json:"ready"`
+	Target  string        `json:"target"`
+	Version string        `json:"version,omitempty"`
+}
+
+// version api representation of the appliance version.
+type version struct {
+	Build   string `json:"build"`
+	Product string `json:"product"`
+	Version string `json:"version"`
+}
+
+// Status check the target can be reached and authenticated with, results are cached for the health check interval.
+func (v *Vsphere) Status(ctx context.Context) Status {
+	config := v.config.Load()
+
+	v.health.Lock()
+	cached := v.status
+	v.health.Unlock()
+
+	if cached != nil && time.Since(cached.Checked) < config.HealthCheckInterval {
+		// Breaker state changes between checks so it is never cached
+		status := *cached
+		status.Breaker = v.breakers.status(config.Server.Host)
+
+		return status
+	}
+
+	// Checks run without holding the lock so a slow vsphere doesn't block callers reading a cached result
+	status := v.check(ctx, config)
+
+	v.health.Lock()
+	if v.status == nil || status.Checked.After(v.status.Checked) {
+		v.status = &status
+	}
+	v.health.Unlock()
+
+	status.Breaker = v.breakers.status(config.Server.Host)
+
+	return status
+}
+
+// check run each readiness check against the target.
+func (v *Vsphere) check(ctx context.Context, config *configuration.Configuration) Status {
+	status := Status{Checked: time.Now(), Checks: make([]Check, 0), Ready: true, Target: config.Server.Host}
+
+	run := func(name string, check func(ctx context.Context) (string, error)) bool {
+		ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+		defer cancel()
+
+		started := time.Now()
+		message, err := check(ctx)
+
+		result := Check{Duration: time.Since(started).Round(time.Millisecond).String(), Message: message, Name: name, Status: CheckOK}
+		if err != nil {
+			result.Message = err.Error()
+			result.Status = CheckFailed
+			status.Ready = false
+		}
+
+		status.Checks = append(status.Checks, result)
+
+		return err == nil
+	}
+
+	skip := func(names ...string) {
+		for _, name := range names {
+			status.Checks = append(status.Checks, Check{Duration: "0s", Message: "not checked", Name: name, Status: CheckSkipped})
+		}
+	}
+
+	credentials := v.credentials.Get()
+
+	switch {
+	case !run("reachable", v.checkReachable):
+		skip("session", "version")
+	case credentials.Username == "" || credentials.Password == "":
+		// Without configured credentials every request passes through its own credentials
+		status.Checks = append(status.Checks, Check{Duration: "0s", Message: "no credentials configured", Name: "session", Status: CheckSkipped})
+		skip("version")
+	default:
+		var token string
+
+		authenticated := run("session", func(ctx context.Context) (string, error) {
+			var err error
+
+			token, err = v.createSession(ctx, credentials.Username, credentials.Password)
+
+			return "", err
+		})
+		if !authenticated {
+			skip("version")
+
+			break
+		}
+
+		run("version", func(ctx context.Context) (string, error) {
+			var err error
+
+			status.Version, err = v.checkVersion(ctx, token)
+
+			return status.Version, err
+		})
+
+		// Health check sessions are never reused
+		_, _ = v.send(ctx, http.MethodDelete, "/session", nil, header{key: "vmware-api-session-id", value: token})
+	}
+
+	return status
+}
+
+// checkReachable connect to the target through any configured proxy, completing a tls handshake for https targets.
+func (v *Vsphere) checkReachable(ctx context.Context) (string, error) {
+	config := v.config.Load()
+
+	port := config.Server.Port()
+	if port == "" {
+		port = "443"
+		if config.Server.Scheme == "http" {
+			port = "80"
+		}
+	}
+
+	address := net.JoinHostPort(config.Server.Hostname(), port)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodHead, config.Server.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	// Any response means the target can be reached, the status doesn't matter
+	response, err := v.client.Load().Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.TLS == nil {
+		return address, nil
+	}
+
+	return address + " " + tls.VersionName(response.TLS.Version), nil
+}
+
+// checkVersion get the appliance version using a session, forgetting the discovered version if it has changed.
+func (v *Vsphere) checkVersion(ctx context.Context, token string) (string, error) {
+	applianceVersion, err := v.applianceVersion(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	v.discovery.Lock()
+	changed := v.about != nil && v.about.Version != "" && (v.about.Version != applianceVersion.Version || v.about.Build != applianceVersion.Build)
+	v.discovery.Unlock()
+
+	if changed {
+		v.forget()
+	}
+
+	return strings.TrimSpace(applianceVersion.Version + " " + applianceVersion.Build), nil
+}
+
+// applianceVersion read the appliance version using a session.
+func (v *Vsphere) applianceVersion(ctx context.Context, token string) (version, error) {
+	response, err := v.send(ctx, http.MethodGet, "/appliance/system/version", nil, header{key: "vmware-api-session-id", value: token})
+	if err != nil {
+		return version{}, err
+	}
+
+	var applianceVersion version
+	err = json.Unmarshal(response, &applianceVersion)
+	if err != nil {
+		return version{}, err
+	}
+
+	return applianceVersion, nil
+}
+
+// createSession create a session which isn't shared with requests.
+func (v *Vsphere) createSession(ctx context.Context, username string, password string) (string, error) {
+	authorization := "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+
+	response, err := v.send(ctx, http.MethodPost, "/session", nil, header{key: "Authorization", value: authorization})
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Trim(string(response), `"`), nil
+}
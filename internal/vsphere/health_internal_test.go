@@ -0,0 +1,110 @@
+package vsphere
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/credentials"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+// slowServer vsphere api which holds version requests until released.
+type slowServer struct {
+	release  chan struct{}
+	versions atomic.Int32
+}
+
+// ServeHTTP handle session and version requests.
+func (s *slowServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	switch {
+	case request.Method == http.MethodPost && request.URL.Path == "/api/session":
+		_, _ = io.WriteString(writer, `"token"`)
+	case request.URL.Path == "/api/appliance/system/version":
+		s.versions.Add(1)
+
+		select {
+		case <-s.release:
+		case <-request.Context().Done():
+			return
+		}
+
+		_, _ = io.WriteString(writer, `{"version":"8.0.2","build":"1"}`)
+	default:
+		writer.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestVsphere_StatusWhileChecking(t *testing.T) {
+	t.Parallel()
+
+	slow := &slowServer{release: make(chan struct{})}
+	server := httptest.NewServer(slow)
+	t.Cleanup(server.Close)
+
+	released := false
+	t.Cleanup(func() {
+		if !released {
+			close(slow.release)
+		}
+	})
+
+	target, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	config := &configuration.Configuration{
+		HealthCheckInterval: time.Minute,
+		Password:            "password",
+		RetryAttempts:       1,
+		RetryTimeout:        5 * time.Second,
+		Server:              target,
+		Username:            "service",
+	}
+
+	logger, err := logging.New(logging.Error, io.Discard, 0)
+	require.NoError(t, err)
+
+	store, err := credentials.New(config, logger)
+	require.NoError(t, err)
+
+	api, err := New(config, store, logger)
+	require.NoError(t, err)
+
+	checked := make(chan Status, 1)
+
+	go func() {
+		checked <- api.Status(context.Background())
+	}()
+
+	require.Eventually(t, func() bool { return slow.versions.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// A caller with a short deadline gives up on its own checks rather than waiting for the slow check to finish
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	status := api.Status(ctx)
+	assert.False(t, status.Ready)
+	assert.Less(t, time.Since(started), 2*time.Second)
+
+	close(slow.release)
+	released = true
+
+	status = <-checked
+	assert.True(t, status.Ready)
+	assert.Equal(t, "8.0.2 1", status.Version)
+
+	// The most recently started check is cached
+	status = api.Status(context.Background())
+	assert.False(t, status.Ready)
+	assert.Equal(t, int32(2), slow.versions.Load())
+}
@@ -1,9 +1,10 @@
 package vsphere
 
 import (
-	"crypto/tls"
+	"context"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strings"
 
@@ -18,57 +19,132 @@ type header struct {
 	value string
 }
 
-// Request send an authenticated http request.
+// Reply response received from vsphere.
+type Reply struct {
+	Body        []byte
+	ContentType string
+	Status      int
+}
+
+// Request send an authenticated http request, idempotent requests which fail with a transient error are retried.
 func (v *Vsphere) Request(ctx echo.Context, method string, path string, payload io.Reader) ([]byte, error) {
-	if v.token == "" {
-		err := v.authenticate(ctx)
+	return v.RequestVerified(ctx, method, path, payload, nil)
+}
+
+// RequestVerified send an authenticated http request, non-idempotent requests which fail with a transient error are
+// only retried if verify reports the request didn't take effect.
+func (v *Vsphere) RequestVerified(ctx echo.Context, method string, path string, payload io.Reader, verify Verify) ([]byte, error) {
+	reply, err := v.exchange(ctx, method, path, payload, verify)
+	if err != nil {
+		return nil, err
+	}
+
+	return reply.Body, nil
+}
+
+// Forward send an authenticated http request and return the reply, the reply from vsphere is also returned alongside
+// an error if vsphere rejected the request.
+func (v *Vsphere) Forward(ctx echo.Context, method string, path string, payload io.Reader) (Reply, error) {
+	return v.exchange(ctx, method, path, payload, nil)
+}
+
+// exchange send an authenticated http request, retrying it when safe.
+func (v *Vsphere) exchange(ctx echo.Context, method string, path string, payload io.Reader, verify Verify) (Reply, error) {
+	current, err := v.open(ctx)
+	if err != nil {
+		return Reply{}, errors.Wrap(err, "unable to authenticate with vsphere api")
+	}
+
+	defer func() {
+		if current != nil {
+			v.close(current)
+		}
+	}()
+
+	var body []byte
+	if payload != nil {
+		body, err = io.ReadAll(payload)
 		if err != nil {
-			return nil, errors.Wrap(err, "unable to authenticate with vsphere api")
+			return Reply{}, errors.Wrap(err, "unable to read request payload")
 		}
-		defer v.logout(ctx)
 	}
 
-	return v.request(method, path, payload)
+	return v.retry(ctx.Request().Context(), call{
+		idempotent: idempotent(method),
+		method:     method,
+		path:       path,
+		payload:    body,
+		reauthenticate: func() (string, error) {
+			renewed, err := v.renew(ctx, current)
+			if err != nil {
+				current = nil
+
+				return "", err
+			}
+
+			current = renewed
+
+			return current.token, nil
+		},
+		token:  current.token,
+		verify: verify,
+	})
+}
+
+// send an http request which is cancelled with a context.
+func (v *Vsphere) send(ctx context.Context, method string, path string, payload io.Reader, headers ...header) ([]byte, error) {
+	reply, err := v.transmit(ctx, method, path, payload, headers...)
+	if err != nil {
+		return nil, err
+	}
+
+	return reply.Body, nil
 }
 
-// request send an http request.
-func (v *Vsphere) request(method string, path string, payload io.Reader, headers ...header) ([]byte, error) {
-	request, err := http.NewRequest(method, fmt.Sprintf("%s/api/%s", v.config.Server, strings.TrimPrefix(path, "/")), payload)
+// transmit send an http request which is cancelled with a context and return the reply.
+func (v *Vsphere) transmit(ctx context.Context, method string, path string, payload io.Reader, headers ...header) (Reply, error) {
+	reply, err := v.do(ctx, method, path, payload, headers...)
 	if err != nil {
-		return nil, errors.Wrap(err, "unable to create http request")
+		return reply, errors.With(err, "target", v.config.Load().Server.Host, "method", method, "path", path)
 	}
 
-	for _, reqHeader := range headers {
-		request.Header.Add(reqHeader.key, reqHeader.value)
+	return reply, nil
+}
+
+// do send an http request and read the response.
+func (v *Vsphere) do(ctx context.Context, method string, path string, payload io.Reader, headers ...header) (Reply, error) {
+	request, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s/api/%s", v.config.Load().Server, strings.TrimPrefix(path, "/")), payload)
+	if err != nil {
+		return Reply{}, errors.Wrap(err, "unable to create http request")
 	}
 
-	if v.token != "" {
-		request.Header.Set("vmware-api-session-id", v.token)
+	for _, reqHeader := range headers {
+		request.Header.Add(reqHeader.key, reqHeader.value)
 	}
 
 	request.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	if v.config.Insecure {
-		client = &http.Client{Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}}
-	}
-
-	response, err := client.Do(request)
+	response, err := v.client.Load().Do(request)
 	if err != nil {
-		return nil, errors.Wrap(err, "error sending http request")
+		var netError net.Error
+		if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netError) && netError.Timeout()) {
+			return Reply{}, errors.WrapKind(err, errors.KindTimeout, "timed out sending http request")
+		}
+
+		return Reply{}, errors.WrapKind(err, errors.KindUpstream, "error sending http request")
 	}
 	defer func() { _ = response.Body.Close() }()
 
 	body, err := io.ReadAll(response.Body)
 	if err != nil {
-		return nil, errors.Wrap(err, "error reading response body")
+		return Reply{}, errors.WrapKind(err, errors.KindUpstream, "error reading response body")
 	}
 
+	reply := Reply{Body: body, ContentType: response.Header.Get("Content-Type"), Status: response.StatusCode}
+
 	if response.StatusCode >= http.StatusBadRequest {
-		return nil, errors.New("unexpected response received from server (%s): %s", response.Status, string(body))
+		return reply, errors.Wrap(errors.ParseAPIError(response.StatusCode, body), "unexpected response received from server")
 	}
 
-	return body, nil
+	return reply, nil
 }
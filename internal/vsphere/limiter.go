@@ -0,0 +1,90 @@
+package vsphere
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+// limiter limits the rate and concurrency of calls to vsphere.
+type limiter struct {
+	active  int
+	bucket  *rate.Limiter
+	freed   chan struct{}
+	maximum int
+	mutex   sync.Mutex
+}
+
+// newLimiter create a new limiter, a rate or maximum of zero disables that limit.
+func newLimiter(perSecond int, burst int, maximum int) *limiter {
+	return &limiter{
+		bucket:  rate.NewLimiter(limit(perSecond), burst),
+		freed:   make(chan struct{}),
+		maximum: maximum,
+	}
+}
+
+// acquire wait for a token and a free slot, the slot must be released once the call completes.
+func (l *limiter) acquire(ctx context.Context) error {
+	err := l.bucket.Wait(ctx)
+	if err != nil {
+		return errors.WrapKind(err, errors.KindUnavailable, "vsphere rate limit exceeded")
+	}
+
+	for {
+		l.mutex.Lock()
+		if l.maximum <= 0 || l.active < l.maximum {
+			l.active++
+			l.mutex.Unlock()
+
+			return nil
+		}
+
+		freed := l.freed
+		l.mutex.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return errors.WrapKind(ctx.Err(), errors.KindUnavailable, "vsphere concurrency limit of %d reached", l.maximum)
+		case <-freed:
+		}
+	}
+}
+
+// configure change limits, calls in progress are unaffected.
+func (l *limiter) configure(perSecond int, burst int, maximum int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.bucket.SetLimit(limit(perSecond))
+	l.bucket.SetBurst(burst)
+	l.maximum = maximum
+	l.wake()
+}
+
+// release a slot acquired for a call.
+func (l *limiter) release() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.active--
+	l.wake()
+}
+
+// wake calls waiting for a slot, must be called while holding the mutex.
+func (l *limiter) wake() {
+	close(l.freed)
+	l.freed = make(chan struct{})
+}
+
+// limit convert calls per second to a rate limit.
+func limit(perSecond int) rate.Limit {
+	if perSecond <= 0 {
+		return rate.Inf
+	}
+
+	return rate.Limit(perSecond)
+}
@@ -0,0 +1,123 @@
+package vsphere
+
+import (
+	"sync"
+	"time"
+)
+
+// lockout tracks failed authentication attempts and locks out keys which fail repeatedly.
+type lockout struct {
+	base      time.Duration
+	entries   map[string]*attempts
+	max       time.Duration
+	mutex     sync.Mutex
+	now       func() time.Time
+	threshold int
+}
+
+// attempts failed authentication attempts for a single key.
+type attempts struct {
+	failures int
+	last     time.Time
+	until    time.Time
+}
+
+// newLockout create a new lockout, a threshold of zero disables lockouts.
+func newLockout(threshold int, base time.Duration, maximum time.Duration) *lockout {
+	return &lockout{
+		base:      base,
+		entries:   make(map[string]*attempts),
+		max:       maximum,
+		now:       time.Now,
+		threshold: threshold,
+	}
+}
+
+// configure change lockout settings, existing failures are kept.
+func (l *lockout) configure(threshold int, base time.Duration, maximum time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.base = base
+	l.max = maximum
+	l.threshold = threshold
+}
+
+// fail record a failed attempt against each key, returning the longest lockout applied.
+func (l *lockout) fail(keys ...string) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.threshold <= 0 {
+		return 0
+	}
+
+	now := l.now()
+	l.prune(now)
+
+	var longest time.Duration
+
+	for _, key := range keys {
+		entry, ok := l.entries[key]
+		if !ok {
+			entry = &attempts{}
+			l.entries[key] = entry
+		}
+
+		entry.failures++
+		entry.last = now
+
+		if entry.failures < l.threshold {
+			continue
+		}
+
+		// Double the lockout for every failure past the threshold
+		duration := l.max
+		if shift := entry.failures - l.threshold; shift < 32 {
+			duration = min(l.base<<shift, l.max)
+		}
+
+		entry.until = now.Add(duration)
+		longest = max(longest, duration)
+	}
+
+	return longest
+}
+
+// locked get the remaining lockout for the most restricted key, zero if no key is locked out.
+func (l *lockout) locked(keys ...string) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+
+	var remaining time.Duration
+
+	for _, key := range keys {
+		entry, ok := l.entries[key]
+		if ok {
+			remaining = max(remaining, entry.until.Sub(now))
+		}
+	}
+
+	return remaining
+}
+
+// succeed clear failed attempts for each key.
+func (l *lockout) succeed(keys ...string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, key := range keys {
+		delete(l.entries, key)
+	}
+}
+
+// prune forget keys which are no longer locked out and haven't failed recently, must be called while holding the mutex.
+func (l *lockout) prune(now time.Time) {
+	for key, entry := range l.entries {
+		if now.After(entry.until) && now.Sub(entry.last) > l.max {
+			delete(l.entries, key)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package vsphere
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/credentials"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+// step a single action taken against a lockout.
+type step struct {
+	advance time.Duration
+	fail    string
+	succeed string
+}
+
+func TestLockout(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		threshold int
+		steps     []step
+		check     []string
+		expected  time.Duration
+	}{
+		"below threshold": {
+			threshold: 3,
+			steps:     []step{{fail: "a"}, {fail: "a"}},
+			check:     []string{"a"},
+			expected:  0,
+		},
+		"at threshold": {
+			threshold: 3,
+			steps:     []step{{fail: "a"}, {fail: "a"}, {fail: "a"}},
+			check:     []string{"a"},
+			expected:  time.Minute,
+		},
+		"doubles past threshold": {
+			threshold: 2,
+			steps:     []step{{fail: "a"}, {fail: "a"}, {fail: "a"}, {fail: "a"}},
+			check:     []string{"a"},
+			expected:  4 * time.Minute,
+		},
+		"capped at maximum": {
+			threshold: 1,
+			steps:     []step{{fail: "a"}, {fail: "a"}, {fail: "a"}, {fail: "a"}, {fail: "a"}, {fail: "a"}},
+			check:     []string{"a"},
+			expected:  10 * time.Minute,
+		},
+		"disabled": {
+			threshold: 0,
+			steps:     []step{{fail: "a"}, {fail: "a"}, {fail: "a"}},
+			check:     []string{"a"},
+			expected:  0,
+		},
+		"other key": {
+			threshold: 1,
+			steps:     []step{{fail: "a"}},
+			check:     []string{"b"},
+			expected:  0,
+		},
+		"most restricted key": {
+			threshold: 1,
+			steps:     []step{{fail: "a"}, {fail: "b"}, {fail: "b"}},
+			check:     []string{"a", "b"},
+			expected:  2 * time.Minute,
+		},
+		"remaining lockout": {
+			threshold: 1,
+			steps:     []step{{fail: "a"}, {advance: 20 * time.Second}},
+			check:     []string{"a"},
+			expected:  40 * time.Second,
+		},
+		"lockout expires": {
+			threshold: 1,
+			steps:     []step{{fail: "a"}, {advance: time.Minute}},
+			check:     []string{"a"},
+			expected:  0,
+		},
+		"failures within window are counted": {
+			threshold: 3,
+			steps:     []step{{fail: "a"}, {fail: "a"}, {advance: 9 * time.Minute}, {fail: "a"}},
+			check:     []string{"a"},
+			expected:  time.Minute,
+		},
+		"failures outside window are forgotten": {
+			threshold: 3,
+			steps:     []step{{fail: "a"}, {fail: "a"}, {advance: 11 * time.Minute}, {fail: "a"}},
+			check:     []string{"a"},
+			expected:  0,
+		},
+		"success resets failures": {
+			threshold: 3,
+			steps:     []step{{fail: "a"}, {fail: "a"}, {succeed: "a"}, {fail: "a"}, {fail: "a"}},
+			check:     []string{"a"},
+			expected:  0,
+		},
+		"success ends lockout": {
+			threshold: 1,
+			steps:     []step{{fail: "a"}, {succeed: "a"}},
+			check:     []string{"a"},
+			expected:  0,
+		},
+		"success only resets its own key": {
+			threshold: 1,
+			steps:     []step{{fail: "a"}, {fail: "b"}, {succeed: "b"}},
+			check:     []string{"a", "b"},
+			expected:  time.Minute,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			guard := newLockout(testcase.threshold, time.Minute, 10*time.Minute)
+			guard.now = func() time.Time { return now }
+
+			for _, action := range testcase.steps {
+				now = now.Add(action.advance)
+
+				if action.fail != "" {
+					guard.fail(action.fail)
+				}
+
+				if action.succeed != "" {
+					guard.succeed(action.succeed)
+				}
+			}
+
+			assert.Equal(t, testcase.expected, guard.locked(testcase.check...))
+		})
+	}
+}
+
+func TestVsphere_AuthenticateLockout(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, password, _ := request.BasicAuth()
+		if request.URL.Path != "/api/session" || password != "good" {
+			writer.WriteHeader(http.StatusUnauthorized)
+			_, _ = io.WriteString(writer, `{"error_type":"UNAUTHENTICATED"}`)
+
+			return
+		}
+
+		_, _ = io.WriteString(writer, `"token"`)
+	}))
+	t.Cleanup(server.Close)
+
+	target, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	basic := func(username string, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+	}
+
+	testcases := map[string]struct {
+		attempts []string
+		sources  []string
+		last     string
+		source   string
+		expected int
+	}{
+		"username locked from another source": {
+			attempts: []string{basic("alice", "bad"), basic("alice", "bad")},
+			sources:  []string{"192.0.2.1", "192.0.2.2"},
+			last:     basic("alice", "good"),
+			source:   "192.0.2.3",
+			expected: http.StatusTooManyRequests,
+		},
+		"source locked for another username": {
+			attempts: []string{basic("alice", "bad"), basic("bob", "bad")},
+			sources:  []string{"192.0.2.1", "192.0.2.1"},
+			last:     basic("carol", "good"),
+			source:   "192.0.2.1",
+			expected: http.StatusTooManyRequests,
+		},
+		"other usernames and sources": {
+			attempts: []string{basic("alice", "bad"), basic("alice", "bad")},
+			sources:  []string{"192.0.2.1", "192.0.2.1"},
+			last:     basic("bob", "good"),
+			source:   "192.0.2.2",
+			expected: http.StatusOK,
+		},
+		"credentials without a username don't share a key": {
+			attempts: []string{"Bearer one", "Bearer two"},
+			sources:  []string{"192.0.2.1", "192.0.2.2"},
+			last:     "Bearer three",
+			source:   "192.0.2.3",
+			expected: http.StatusUnauthorized,
+		},
+		"success resets the username": {
+			attempts: []string{basic("alice", "bad"), basic("alice", "good"), basic("alice", "bad")},
+			sources:  []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"},
+			last:     basic("alice", "good"),
+			source:   "192.0.2.4",
+			expected: http.StatusOK,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := &configuration.Configuration{
+				LockoutDuration:    time.Minute,
+				LockoutMaxDuration: time.Hour,
+				LockoutThreshold:   2,
+				RetryAttempts:      1,
+				RetryTimeout:       5 * time.Second,
+				Server:             target,
+			}
+
+			logger, err := logging.New(logging.Error, io.Discard, 0)
+			require.NoError(t, err)
+
+			store, err := credentials.New(config, logger)
+			require.NoError(t, err)
+
+			api, err := New(config, store, logger)
+			require.NoError(t, err)
+
+			authenticate := func(credentials string, source string) (echo.Context, error) {
+				request := httptest.NewRequest(http.MethodGet, "/", nil)
+				request.Header.Set("Authorization", credentials)
+				request.RemoteAddr = source + ":5000"
+
+				ctx := echo.New().NewContext(request, httptest.NewRecorder())
+				_, err := api.authenticate(ctx, credentials, true)
+
+				return ctx, err
+			}
+
+			for index, attempt := range testcase.attempts {
+				_, _ = authenticate(attempt, testcase.sources[index])
+			}
+
+			ctx, err := authenticate(testcase.last, testcase.source)
+
+			switch testcase.expected {
+			case http.StatusOK:
+				require.NoError(t, err)
+			case http.StatusTooManyRequests:
+				var httpError *echo.HTTPError
+				require.ErrorAs(t, err, &httpError)
+				assert.Equal(t, http.StatusTooManyRequests, httpError.Code)
+				assert.Equal(t, "60", ctx.Response().Header().Get("Retry-After"))
+			default:
+				require.Error(t, err)
+				assert.Equal(t, errors.KindUnauthenticated, errors.KindOf(err))
+				assert.Empty(t, ctx.Response().Header().Get("Retry-After"))
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package passthrough
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/identity"
+	"github.com/sjdaws/vsphere-bridge/internal/vsphere"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+// maxPayload largest request body which will be forwarded.
+const maxPayload = 1 << 20
+
+// Passthrough forwards requests to the vsphere api using the bridge's session handling. Only the method and path are
+// checked, policies, power limits and the power on queue aren't applied to forwarded requests.
+type Passthrough struct {
+	logger  logging.Logger
+	rules   atomic.Pointer[[]rule]
+	vsphere *vsphere.Vsphere
+}
+
+// rule method and path pattern which can be forwarded.
+type rule struct {
+	method  string
+	pattern string
+}
+
+// New create a new Passthrough instance.
+func New(config *configuration.Configuration, vsphere *vsphere.Vsphere, logger logging.Logger, server *echo.Echo, middleware ...echo.MiddlewareFunc) (*Passthrough, error) {
+	api := &Passthrough{
+		logger:  logger,
+		vsphere: vsphere,
+	}
+
+	apply, err := api.Prepare(config)
+	if err != nil {
+		return nil, err
+	}
+
+	apply()
+
+	group := server.Group("/api", middleware...)
+	group.Any("/*", api.Forward)
+
+	return api, nil
+}
+
+// Prepare parse the allowlist from a configuration and return a function which applies it.
+func (p *Passthrough) Prepare(config *configuration.Configuration) (func(), error) {
+	rules, err := parseRules(config.APIPassthrough)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() { p.rules.Store(&rules) }, nil
+}
+
+// Forward send a request to the vsphere api if the method and path are allowed, returning the reply from vsphere.
+func (p *Passthrough) Forward(ctx echo.Context) error {
+	rules := *p.rules.Load()
+	if len(rules) == 0 {
+		return echo.ErrNotFound
+	}
+
+	started := time.Now()
+	method := ctx.Request().Method
+	target := path.Clean("/" + strings.TrimPrefix(ctx.Request().URL.Path, "/api"))
+
+	if !allowed(rules, method, target) {
+		p.audit(ctx, started, target, 0, http.StatusForbidden)
+
+		return echo.NewHTTPError(http.StatusForbidden, "request to "+method+" "+target+" is not allowed")
+	}
+
+	err := p.vsphere.Require(ctx.Request().Context(), vsphere.CapabilityAPI)
+	if err != nil {
+		p.audit(ctx, started, target, 0, http.StatusNotImplemented)
+
+		return errors.Wrap(err, "unable to forward request to vsphere api")
+	}
+
+	payload, err := readPayload(ctx)
+	if err != nil {
+		p.audit(ctx, started, target, 0, http.StatusRequestEntityTooLarge)
+
+		return err
+	}
+
+	forward := (&url.URL{Path: target, RawQuery: ctx.Request().URL.RawQuery}).String()
+
+	var body io.Reader
+	if len(payload) > 0 {
+		body = bytes.NewReader(payload)
+	}
+
+	reply, err := p.vsphere.Forward(ctx, method, forward, body)
+
+	// Errors returned by vsphere are passed through as they are
+	if err != nil && reply.Status == 0 {
+		p.audit(ctx, started, target, len(payload), 0)
+
+		return errors.Wrap(err, "unable to forward request to vsphere api")
+	}
+
+	p.audit(ctx, started, target, len(payload), reply.Status)
+
+	if len(reply.Body) == 0 {
+		return ctx.NoContent(reply.Status)
+	}
+
+	contentType := reply.ContentType
+	if contentType == "" {
+		contentType = echo.MIMEApplicationJSON
+	}
+
+	return ctx.Blob(reply.Status, contentType, reply.Body)
+}
+
+// audit log a forwarded request, a zero status means the request failed before vsphere replied.
+func (p *Passthrough) audit(ctx echo.Context, started time.Time, target string, size int, status int) {
+	request := ctx.Request()
+
+	log := p.logger.Info
+	if status == http.StatusForbidden {
+		log = p.logger.Warn
+	}
+
+	log(
+		"api passthrough: client=%s source=%s method=%s path=%s query=%q bytes=%d status=%d duration=%s request=%s",
+		identity.FromContext(ctx).Name,
+		ctx.RealIP(),
+		request.Method,
+		target,
+		request.URL.RawQuery,
+		size,
+		status,
+		time.Since(started).Round(time.Millisecond),
+		ctx.Response().Header().Get(echo.HeaderXRequestID),
+	)
+}
@@ -0,0 +1,91 @@
+package passthrough
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+// parseRules parse comma separated METHOD /path patterns, e.g. GET /vcenter/vm/*,POST /vcenter/vm/*/power. A * method
+// matches any method, * in a path matches a single segment and a trailing /** matches zero or more remaining segments.
+func parseRules(value string) ([]rule, error) {
+	rules := make([]rule, 0)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		fields := strings.Fields(entry)
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "/") {
+			return nil, errors.New("invalid api passthrough rule %s, expected METHOD /path", entry)
+		}
+
+		_, err := path.Match(strings.TrimSuffix(fields[1], "/**"), "")
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid api passthrough pattern %s", fields[1])
+		}
+
+		rules = append(rules, rule{method: strings.ToUpper(fields[0]), pattern: fields[1]})
+	}
+
+	return rules, nil
+}
+
+// allowed determine if any rule permits a method and path.
+func allowed(rules []rule, method string, target string) bool {
+	for _, candidate := range rules {
+		if candidate.method != "*" && candidate.method != method {
+			continue
+		}
+
+		if candidate.matches(target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matches determine if a path matches a rule's pattern.
+func (r rule) matches(target string) bool {
+	pattern, remaining := strings.CutSuffix(r.pattern, "/**")
+	if !remaining {
+		matched, _ := path.Match(pattern, target)
+
+		return matched
+	}
+
+	// Match the leading segments against the pattern, anything after them is allowed
+	segments := strings.Count(pattern, "/")
+	parts := strings.SplitAfterN(target, "/", segments+2)
+	if len(parts) <= segments {
+		return false
+	}
+
+	prefix := strings.TrimSuffix(strings.Join(parts[:segments+1], ""), "/")
+	matched, _ := path.Match(pattern, prefix)
+
+	return matched
+}
+
+// readPayload read the request body, rejecting bodies which are too large to forward.
+func readPayload(ctx echo.Context) ([]byte, error) {
+	payload, err := io.ReadAll(http.MaxBytesReader(ctx.Response(), ctx.Request().Body, maxPayload))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, echo.NewHTTPError(http.StatusRequestEntityTooLarge, "request body is too large to forward")
+		}
+
+		return nil, errors.Wrap(err, "unable to read request body")
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,337 @@
+package passthrough
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/credentials"
+	"github.com/sjdaws/vsphere-bridge/internal/vsphere"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+func TestParseRules(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		value    string
+		expected []rule
+		err      bool
+	}{
+		"empty": {
+			value:    "",
+			expected: []rule{},
+		},
+		"single": {
+			value:    "GET /vcenter/vm",
+			expected: []rule{{method: "GET", pattern: "/vcenter/vm"}},
+		},
+		"multiple": {
+			value: " get /vcenter/vm/** ,POST /vcenter/vm/*/power,, * /cis/tagging/*",
+			expected: []rule{
+				{method: "GET", pattern: "/vcenter/vm/**"},
+				{method: "POST", pattern: "/vcenter/vm/*/power"},
+				{method: "*", pattern: "/cis/tagging/*"},
+			},
+		},
+		"everything": {
+			value:    "* /**",
+			expected: []rule{{method: "*", pattern: "/**"}},
+		},
+		"missing method": {
+			value: "/vcenter/vm",
+			err:   true,
+		},
+		"missing path": {
+			value: "GET",
+			err:   true,
+		},
+		"relative path": {
+			value: "GET vcenter/vm",
+			err:   true,
+		},
+		"too many fields": {
+			value: "GET /vcenter/vm extra",
+			err:   true,
+		},
+		"invalid pattern": {
+			value: "GET /vcenter/[vm",
+			err:   true,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rules, err := parseRules(testcase.value)
+			if testcase.err {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testcase.expected, rules)
+		})
+	}
+}
+
+func TestRule_Matches(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		pattern  string
+		target   string
+		expected bool
+	}{
+		"exact": {
+			pattern:  "/vcenter/vm",
+			target:   "/vcenter/vm",
+			expected: true,
+		},
+		"exact doesn't match children": {
+			pattern:  "/vcenter/vm",
+			target:   "/vcenter/vm/vm-1",
+			expected: false,
+		},
+		"single segment": {
+			pattern:  "/vcenter/vm/*",
+			target:   "/vcenter/vm/vm-1",
+			expected: true,
+		},
+		"single segment doesn't match deeper paths": {
+			pattern:  "/vcenter/vm/*",
+			target:   "/vcenter/vm/vm-1/power",
+			expected: false,
+		},
+		"single segment doesn't match parent": {
+			pattern:  "/vcenter/vm/*",
+			target:   "/vcenter/vm",
+			expected: false,
+		},
+		"segment in the middle": {
+			pattern:  "/vcenter/vm/*/power",
+			target:   "/vcenter/vm/vm-1/power",
+			expected: true,
+		},
+		"segment in the middle with another suffix": {
+			pattern:  "/vcenter/vm/*/guest",
+			target:   "/vcenter/vm/vm-1/power",
+			expected: false,
+		},
+		"remaining segments": {
+			pattern:  "/vcenter/vm/**",
+			target:   "/vcenter/vm/vm-1/hardware/disk/2000",
+			expected: true,
+		},
+		"remaining segments with one segment": {
+			pattern:  "/vcenter/vm/**",
+			target:   "/vcenter/vm/vm-1",
+			expected: true,
+		},
+		"remaining segments match parent": {
+			pattern:  "/vcenter/vm/**",
+			target:   "/vcenter/vm",
+			expected: true,
+		},
+		"remaining segments don't match siblings": {
+			pattern:  "/vcenter/vm/**",
+			target:   "/vcenter/vmx/vm-1",
+			expected: false,
+		},
+		"remaining segments after a wildcard": {
+			pattern:  "/vcenter/vm/*/hardware/**",
+			target:   "/vcenter/vm/vm-1/hardware/disk",
+			expected: true,
+		},
+		"everything": {
+			pattern:  "/**",
+			target:   "/appliance/system/version",
+			expected: true,
+		},
+		"everything matches a single segment": {
+			pattern:  "/**",
+			target:   "/session",
+			expected: true,
+		},
+		"everything matches root": {
+			pattern:  "/**",
+			target:   "/",
+			expected: true,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, testcase.expected, rule{method: "GET", pattern: testcase.pattern}.matches(testcase.target))
+		})
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	t.Parallel()
+
+	rules, err := parseRules("GET /vcenter/vm/**,POST /vcenter/vm/*/power,* /cis/tagging/tag/*")
+	require.NoError(t, err)
+
+	testcases := map[string]struct {
+		method   string
+		target   string
+		expected bool
+	}{
+		"matching method": {
+			method:   http.MethodGet,
+			target:   "/vcenter/vm/vm-1",
+			expected: true,
+		},
+		"other method": {
+			method:   http.MethodDelete,
+			target:   "/vcenter/vm/vm-1",
+			expected: false,
+		},
+		"second rule": {
+			method:   http.MethodPost,
+			target:   "/vcenter/vm/vm-1/power",
+			expected: true,
+		},
+		"any method": {
+			method:   http.MethodPatch,
+			target:   "/cis/tagging/tag/urn:tag:1",
+			expected: true,
+		},
+		"no matching path": {
+			method:   http.MethodGet,
+			target:   "/appliance/system/version",
+			expected: false,
+		},
+		"no rules": {
+			method:   http.MethodGet,
+			expected: false,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			candidates := rules
+			if testcase.target == "" {
+				candidates = nil
+			}
+
+			assert.Equal(t, testcase.expected, allowed(candidates, testcase.method, testcase.target))
+		})
+	}
+}
+
+func TestPassthrough_Forward(t *testing.T) {
+	t.Parallel()
+
+	target := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch {
+		case request.Method == http.MethodPost && request.URL.Path == "/api/session":
+			_, _ = io.WriteString(writer, `"token"`)
+		case request.URL.Path == "/api/appliance/system/version":
+			_, _ = io.WriteString(writer, `{"version":"8.0.2","build":"1"}`)
+		default:
+			_, _ = io.WriteString(writer, `"`+request.Method+" "+request.URL.Path+`"`)
+		}
+	}))
+	t.Cleanup(target.Close)
+
+	server, err := url.Parse(target.URL)
+	require.NoError(t, err)
+
+	config := &configuration.Configuration{
+		APIPassthrough:      "GET /vcenter/vm/**",
+		HealthCheckInterval: time.Minute,
+		Password:            "password",
+		RetryAttempts:       1,
+		RetryTimeout:        5 * time.Second,
+		Server:              server,
+		Username:            "service",
+	}
+
+	logger, err := logging.New(logging.Error, io.Discard, 0)
+	require.NoError(t, err)
+
+	store, err := credentials.New(config, logger)
+	require.NoError(t, err)
+
+	api, err := vsphere.New(config, store, logger)
+	require.NoError(t, err)
+
+	bridge := echo.New()
+
+	_, err = New(config, api, logger, bridge)
+	require.NoError(t, err)
+
+	testcases := map[string]struct {
+		method   string
+		target   string
+		body     string
+		status   int
+		expected string
+	}{
+		"allowed": {
+			method:   http.MethodGet,
+			target:   "/api/vcenter/vm/vm-1",
+			status:   http.StatusOK,
+			expected: `"GET /api/vcenter/vm/vm-1"`,
+		},
+		"cleaned path": {
+			method:   http.MethodGet,
+			target:   "/api/vcenter/vm/vm-1/../vm-2//power",
+			status:   http.StatusOK,
+			expected: `"GET /api/vcenter/vm/vm-2/power"`,
+		},
+		"parent directory": {
+			method: http.MethodGet,
+			target: "/api/vcenter/vm/../../appliance/system/version",
+			status: http.StatusForbidden,
+		},
+		"escaped parent directory": {
+			method: http.MethodGet,
+			target: "/api/vcenter/vm/..%2F..%2Fappliance/system/version",
+			status: http.StatusForbidden,
+		},
+		"method not allowed": {
+			method: http.MethodDelete,
+			target: "/api/vcenter/vm/vm-1",
+			status: http.StatusForbidden,
+		},
+		"body too large": {
+			method: http.MethodGet,
+			target: "/api/vcenter/vm/vm-1",
+			body:   strings.Repeat("a", maxPayload+1),
+			status: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := httptest.NewRecorder()
+			bridge.ServeHTTP(recorder, httptest.NewRequest(testcase.method, testcase.target, strings.NewReader(testcase.body)))
+
+			require.Equal(t, testcase.status, recorder.Code)
+
+			if testcase.expected != "" {
+				assert.Equal(t, testcase.expected, recorder.Body.String())
+			}
+		})
+	}
+}
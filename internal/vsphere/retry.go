@@ -0,0 +1,186 @@
+package vsphere
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+// maxBackoff longest delay between attempts.
+const maxBackoff = 10 * time.Second
+
+// Verify report whether a request which failed with a transient error took effect anyway.
+type Verify func() (bool, error)
+
+// call request which may be retried.
+type call struct {
+	headers        []header
+	idempotent     bool
+	method         string
+	path           string
+	payload        []byte
+	reauthenticate func() (string, error)
+	token          string
+	verify         Verify
+}
+
+// retry send a request until it succeeds, fails with an error which isn't transient, runs out of attempts or the retry
+// timeout expires. Non-idempotent requests are only retried once verify reports they didn't take effect.
+func (v *Vsphere) retry(ctx context.Context, request call) (Reply, error) {
+	config := v.config.Load()
+
+	// Requests run to completion if the caller goes away so power cycles aren't left half done
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), config.RetryTimeout)
+	defer cancel()
+
+	backoff := config.RetryBackoff
+
+	for attempt := 1; ; attempt++ {
+		var payload io.Reader
+		if request.payload != nil {
+			payload = bytes.NewReader(request.payload)
+		}
+
+		response, err := v.attempt(ctx, config.Server.Host, request, payload)
+		if err == nil {
+			return response, nil
+		}
+
+		reason := transient(err, request.reauthenticate != nil)
+		if reason == "" || attempt >= config.RetryAttempts {
+			return response, err
+		}
+
+		if reason != reasonSession && !request.idempotent && request.verify == nil {
+			return response, err
+		}
+
+		v.logger.Warn("%s %s failed with %s, retrying (attempt %d of %d): %v", request.method, request.path, reason, attempt+1, config.RetryAttempts, err)
+
+		// An expired session is rejected before the request is acted on so it can be retried straight away
+		if reason == reasonSession {
+			token, authErr := request.reauthenticate()
+			if authErr != nil {
+				return Reply{}, errors.Wrap(authErr, "unable to renew expired session")
+			}
+
+			request.token = token
+
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return Reply{}, errors.WrapKind(err, errors.KindTimeout, "retry timeout of %s expired", config.RetryTimeout)
+		case <-time.After(jitter(backoff)):
+		}
+
+		backoff = min(backoff*2, maxBackoff)
+
+		if !request.idempotent {
+			done, verifyErr := request.verify()
+			if verifyErr != nil {
+				v.logger.Warn("unable to verify whether %s %s took effect, not retrying: %v", request.method, request.path, verifyErr)
+
+				return Reply{}, err
+			}
+
+			if done {
+				return Reply{}, nil
+			}
+		}
+	}
+}
+
+// attempt send a request once, subject to the circuit breaker for the target and outbound limits.
+func (v *Vsphere) attempt(ctx context.Context, target string, request call, payload io.Reader) (Reply, error) {
+	err := v.limiter.acquire(ctx)
+	if err != nil {
+		return Reply{}, err
+	}
+	defer v.limiter.release()
+
+	err = v.breakers.allow(target)
+	if err != nil {
+		return Reply{}, err
+	}
+
+	headers := request.headers
+	if request.token != "" {
+		headers = append([]header{{key: "vmware-api-session-id", value: request.token}}, headers...)
+	}
+
+	response, err := v.transmit(ctx, request.method, request.path, payload, headers...)
+
+	if v.breakers.record(target, failing(err)) {
+		v.logger.Warn("circuit breaker for %s opened after repeated failures: %v", target, err)
+	}
+
+	return response, err
+}
+
+const (
+	// reasonBusy vsphere reported the resource is busy.
+	reasonBusy = "resource busy"
+	// reasonNetwork vsphere couldn't be reached or the connection was interrupted.
+	reasonNetwork = "network error"
+	// reasonServer vsphere reported an internal error or is unavailable.
+	reasonServer = "server error"
+	// reasonSession vsphere session expired.
+	reasonSession = "expired session"
+)
+
+// transient reason an error is likely to succeed if retried, or an empty string if it isn't.
+func transient(err error, session bool) string {
+	// The retry timeout has expired or the bridge is shutting down
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return ""
+	}
+
+	var apiError errors.APIError
+	if errors.As(err, &apiError) {
+		switch {
+		case apiError.Type == "RESOURCE_BUSY":
+			return reasonBusy
+		case session && apiError.Kind() == errors.KindUnauthenticated:
+			return reasonSession
+		case apiError.Status >= http.StatusInternalServerError && apiError.Status != http.StatusNotImplemented:
+			return reasonServer
+		}
+
+		return ""
+	}
+
+	switch errors.KindOf(err) {
+	case errors.KindTimeout, errors.KindUpstream:
+		return reasonNetwork
+	}
+
+	return ""
+}
+
+// idempotent determine if repeating a request has the same effect as sending it once.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodDelete, http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut:
+		return true
+	}
+
+	return false
+}
+
+// jitter randomise a backoff between half and all of its duration so retries from concurrent requests spread out.
+func jitter(backoff time.Duration) time.Duration {
+	if backoff <= 1 {
+		return backoff
+	}
+
+	half := backoff / 2
+
+	return half + rand.N(backoff-half)
+}
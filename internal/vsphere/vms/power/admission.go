@@ -0,0 +1,207 @@
+package power
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+// admission queue which limits how many virtual machines are powered on at once and staggers power ons so a mass
+// power on after an outage doesn't saturate storage.
+type admission struct {
+	active      int
+	concurrency int
+	last        time.Time
+	mutex       sync.Mutex
+	priorities  []priority
+	stagger     time.Duration
+	timer       *time.Timer
+	waiting     []*ticket
+}
+
+// priority of virtual machines with names matching a pattern.
+type priority struct {
+	pattern string
+	value   int
+}
+
+// ticket place in the admission queue.
+type ticket struct {
+	admitted chan struct{}
+	job      string
+	priority int
+}
+
+// parsePriorities parse comma separated name=priority pairs, names can contain wildcards.
+func parsePriorities(value string) ([]priority, error) {
+	priorities := make([]priority, 0)
+
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		pattern, number, ok := strings.Cut(pair, "=")
+		if !ok || pattern == "" {
+			return nil, errors.New("invalid power on priority %s, expected name=priority", pair)
+		}
+
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid power on priority pattern %s", pattern)
+		}
+
+		parsed, err := strconv.Atoi(number)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid power on priority %s", pair)
+		}
+
+		priorities = append(priorities, priority{pattern: pattern, value: parsed})
+	}
+
+	return priorities, nil
+}
+
+// configure change admission settings, queued power ons keep their place.
+func (a *admission) configure(concurrency int, stagger time.Duration, priorities []priority) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.concurrency = concurrency
+	a.priorities = priorities
+	a.stagger = stagger
+
+	// Reschedule in case the stagger changed
+	if a.timer != nil && a.timer.Stop() {
+		a.timer = nil
+	}
+
+	a.dispatch()
+}
+
+// enqueue a power on for a virtual machine, higher priority virtual machines are admitted first.
+func (a *admission) enqueue(name string, job string) *ticket {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	queued := &ticket{
+		admitted: make(chan struct{}),
+		job:      job,
+		priority: a.priority(name),
+	}
+
+	a.waiting = append(a.waiting, queued)
+	sort.SliceStable(a.waiting, func(i int, j int) bool {
+		return a.waiting[i].priority > a.waiting[j].priority
+	})
+
+	a.dispatch()
+
+	return queued
+}
+
+// wait until a ticket is admitted, leaving the queue if the context is cancelled first.
+func (a *admission) wait(ctx context.Context, queued *ticket) error {
+	select {
+	case <-queued.admitted:
+		return nil
+	case <-ctx.Done():
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for index, waiting := range a.waiting {
+		if waiting == queued {
+			a.waiting = append(a.waiting[:index], a.waiting[index+1:]...)
+
+			return errors.Wrap(ctx.Err(), "request cancelled while queued to power on")
+		}
+	}
+
+	// Admitted while the context was being cancelled, give the slot back
+	a.active--
+	a.dispatch()
+
+	return errors.Wrap(ctx.Err(), "request cancelled while queued to power on")
+}
+
+// release an admitted ticket's slot once the power on has completed.
+func (a *admission) release() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.active--
+	a.dispatch()
+}
+
+// position of a ticket in the queue starting at 1, zero once admitted.
+func (a *admission) position(queued *ticket) int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for index, waiting := range a.waiting {
+		if waiting == queued {
+			return index + 1
+		}
+	}
+
+	return 0
+}
+
+// positionOf a job in the queue starting at 1, zero if the job isn't queued.
+func (a *admission) positionOf(job string) int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for index, waiting := range a.waiting {
+		if waiting.job == job {
+			return index + 1
+		}
+	}
+
+	return 0
+}
+
+// dispatch admit waiting tickets while slots are free and the stagger has elapsed, must be called with the mutex held.
+func (a *admission) dispatch() {
+	for len(a.waiting) > 0 && (a.concurrency == 0 || a.active < a.concurrency) {
+		if wait := time.Until(a.last.Add(a.stagger)); wait > 0 {
+			if a.timer == nil {
+				a.timer = time.AfterFunc(wait, func() {
+					a.mutex.Lock()
+					defer a.mutex.Unlock()
+
+					a.timer = nil
+					a.dispatch()
+				})
+			}
+
+			return
+		}
+
+		next := a.waiting[0]
+		a.waiting = a.waiting[1:]
+		a.active++
+		a.last = time.Now()
+		close(next.admitted)
+	}
+}
+
+// priority of a virtual machine, the first matching pattern is used.
+func (a *admission) priority(name string) int {
+	for _, candidate := range a.priorities {
+		if matched, _ := path.Match(candidate.pattern, name); matched {
+			return candidate.value
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,216 @@
+package power
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// admitted determine whether a ticket has been admitted without waiting for it.
+func admitted(queued *ticket) bool {
+	select {
+	case <-queued.admitted:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestParsePriorities(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		value    string
+		expected []priority
+		err      bool
+	}{
+		"empty": {
+			value:    "",
+			expected: []priority{},
+		},
+		"pairs": {
+			value:    " dc*=100, db*=50,,web1=-1",
+			expected: []priority{{pattern: "dc*", value: 100}, {pattern: "db*", value: 50}, {pattern: "web1", value: -1}},
+		},
+		"missing priority": {
+			value: "dc*",
+			err:   true,
+		},
+		"missing name": {
+			value: "=100",
+			err:   true,
+		},
+		"invalid priority": {
+			value: "dc*=high",
+			err:   true,
+		},
+		"invalid pattern": {
+			value: "dc[=100",
+			err:   true,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			priorities, err := parsePriorities(testcase.value)
+			if testcase.err {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testcase.expected, priorities)
+		})
+	}
+}
+
+func TestAdmission_Concurrency(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		concurrency int
+		enqueued    int
+		expected    int
+	}{
+		"unlimited": {
+			concurrency: 0,
+			enqueued:    5,
+			expected:    5,
+		},
+		"below limit": {
+			concurrency: 3,
+			enqueued:    2,
+			expected:    2,
+		},
+		"at limit": {
+			concurrency: 2,
+			enqueued:    5,
+			expected:    2,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			queue := &admission{}
+			queue.configure(testcase.concurrency, 0, nil)
+
+			tickets := make([]*ticket, 0, testcase.enqueued)
+			for range testcase.enqueued {
+				tickets = append(tickets, queue.enqueue("vm", ""))
+			}
+
+			count := 0
+			for index, queued := range tickets {
+				if admitted(queued) {
+					count++
+
+					continue
+				}
+
+				assert.Equal(t, index-testcase.expected+1, queue.position(queued))
+			}
+
+			assert.Equal(t, testcase.expected, count)
+
+			// Each release admits the next waiting ticket
+			if testcase.expected < testcase.enqueued {
+				queue.release()
+				assert.True(t, admitted(tickets[testcase.expected]))
+				assert.Zero(t, queue.position(tickets[testcase.expected]))
+			}
+		})
+	}
+}
+
+func TestAdmission_Priority(t *testing.T) {
+	t.Parallel()
+
+	priorities, err := parsePriorities("dc*=100,db*=50,test*=-10")
+	require.NoError(t, err)
+
+	queue := &admission{}
+	queue.configure(1, 0, priorities)
+
+	// Hold the only slot so every other power on has to queue
+	require.True(t, admitted(queue.enqueue("first", "")))
+
+	names := []string{"web1", "test1", "db1", "web2", "dc1", "db2", "dc2"}
+	tickets := make(map[string]*ticket, len(names))
+
+	for _, name := range names {
+		tickets[name] = queue.enqueue(name, "job-"+name)
+	}
+
+	assert.Equal(t, 1, queue.positionOf("job-dc1"))
+	assert.Equal(t, 7, queue.positionOf("job-test1"))
+	assert.Zero(t, queue.positionOf("missing"))
+
+	order := make([]string, 0, len(names))
+
+	for range names {
+		queue.release()
+
+		for _, name := range names {
+			if _, done := tickets[name]; done && admitted(tickets[name]) {
+				order = append(order, name)
+				delete(tickets, name)
+			}
+		}
+	}
+
+	assert.Equal(t, []string{"dc1", "dc2", "db1", "db2", "web1", "web2", "test1"}, order)
+}
+
+func TestAdmission_Stagger(t *testing.T) {
+	t.Parallel()
+
+	stagger := 100 * time.Millisecond
+
+	queue := &admission{}
+	queue.configure(0, stagger, nil)
+
+	started := time.Now()
+
+	first := queue.enqueue("vm1", "")
+	second := queue.enqueue("vm2", "")
+
+	assert.True(t, admitted(first))
+	assert.False(t, admitted(second))
+	assert.Equal(t, 1, queue.position(second))
+
+	require.NoError(t, queue.wait(context.Background(), second))
+	assert.GreaterOrEqual(t, time.Since(started), stagger)
+}
+
+func TestAdmission_Cancel(t *testing.T) {
+	t.Parallel()
+
+	queue := &admission{}
+	queue.configure(1, 0, nil)
+
+	holder := queue.enqueue("vm1", "")
+	require.True(t, admitted(holder))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// A cancelled request leaves the queue rather than taking a slot
+	cancelled := queue.enqueue("vm2", "job")
+	require.Error(t, queue.wait(ctx, cancelled))
+	assert.Zero(t, queue.positionOf("job"))
+
+	waiting := queue.enqueue("vm3", "")
+	assert.Equal(t, 1, queue.position(waiting))
+
+	queue.release()
+	assert.True(t, admitted(waiting))
+	assert.False(t, admitted(cancelled))
+}
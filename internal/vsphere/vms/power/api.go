@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/labstack/echo/v4"
 
+	"github.com/sjdaws/vsphere-bridge/internal/vsphere"
 	"github.com/sjdaws/vsphere-bridge/pkg/errors"
 )
 
@@ -19,19 +21,43 @@ type virtualMachine struct {
 	PowerState string `json:"power_state"`
 }
 
+// powerStates state a virtual machine is in once a power action has taken effect.
+var powerStates = map[string]string{
+	"start":   "POWERED_ON",
+	"stop":    "POWERED_OFF",
+	"suspend": "SUSPENDED",
+}
+
+// powerState api representation of the power state of a virtual machine.
+type powerState struct {
+	State string `json:"state"`
+}
+
 // Cycle power cycle a virtual machine.
 func (p *Power) Cycle(ctx echo.Context) error {
-	err := p.performPowerAction(ctx, "stop", virtualMachine{Name: ctx.Param("vm")})
+	vm, err := p.authorize(ctx, "cycle", ctx.Param("vm"))
 	if err != nil {
-		return errors.Wrap(err, "unable to power off virtual machine power")
+		return errors.Wrap(err, "unable to power cycle virtual machine")
 	}
 
-	err = p.performPowerAction(ctx, "start", virtualMachine{Name: ctx.Param("vm")})
+	err = p.limit(ctx, "cycle", vm)
 	if err != nil {
-		return errors.Wrap(err, "unable to power on virtual machine power")
+		return errors.Wrap(err, "unable to power cycle virtual machine")
 	}
 
-	return ctx.JSON(http.StatusOK, map[string]any{"result": "ok"})
+	return p.run(ctx, "cycle", vm, func(ctx echo.Context) error {
+		err := p.performPowerAction(ctx, "stop", vm)
+		if err != nil {
+			return errors.Wrap(err, "unable to power off virtual machine power")
+		}
+
+		err = p.start(ctx, vm)
+		if err != nil {
+			return errors.Wrap(err, "unable to power on virtual machine power")
+		}
+
+		return nil
+	})
 }
 
 // Get power state of a virtual machine.
@@ -46,42 +72,90 @@ func (p *Power) Get(ctx echo.Context) error {
 
 // Off power down a virtual machine.
 func (p *Power) Off(ctx echo.Context) error {
-	err := p.performPowerAction(ctx, "stop", virtualMachine{Name: ctx.Param("vm")})
+	vm, err := p.authorize(ctx, "off", ctx.Param("vm"))
+	if err != nil {
+		return errors.Wrap(err, "unable to power off virtual machine power")
+	}
+
+	err = p.limit(ctx, "off", vm)
 	if err != nil {
 		return errors.Wrap(err, "unable to power off virtual machine power")
 	}
 
-	return ctx.JSON(http.StatusOK, map[string]any{"result": "ok"})
+	return p.run(ctx, "off", vm, func(ctx echo.Context) error {
+		err := p.performPowerAction(ctx, "stop", vm)
+		if err != nil {
+			return errors.Wrap(err, "unable to power off virtual machine power")
+		}
+
+		return nil
+	})
 }
 
 // On power up a virtual machine.
 func (p *Power) On(ctx echo.Context) error {
-	err := p.performPowerAction(ctx, "start", virtualMachine{Name: ctx.Param("vm")})
+	vm, err := p.authorize(ctx, "on", ctx.Param("vm"))
+	if err != nil {
+		return errors.Wrap(err, "unable to power on virtual machine power")
+	}
+
+	err = p.limit(ctx, "on", vm)
 	if err != nil {
 		return errors.Wrap(err, "unable to power on virtual machine power")
 	}
 
-	return ctx.JSON(http.StatusOK, map[string]any{"result": "ok"})
+	return p.run(ctx, "on", vm, func(ctx echo.Context) error {
+		err := p.start(ctx, vm)
+		if err != nil {
+			return errors.Wrap(err, "unable to power on virtual machine power")
+		}
+
+		return nil
+	})
 }
 
 // Reset a virtual machine.
 func (p *Power) Reset(ctx echo.Context) error {
-	err := p.performPowerAction(ctx, "reset", virtualMachine{Name: ctx.Param("vm")})
+	vm, err := p.authorize(ctx, "reset", ctx.Param("vm"))
+	if err != nil {
+		return errors.Wrap(err, "unable to reset virtual machine")
+	}
+
+	err = p.limit(ctx, "reset", vm)
 	if err != nil {
 		return errors.Wrap(err, "unable to reset virtual machine")
 	}
 
-	return ctx.JSON(http.StatusOK, map[string]any{"result": "ok"})
+	return p.run(ctx, "reset", vm, func(ctx echo.Context) error {
+		err := p.performPowerAction(ctx, "reset", vm)
+		if err != nil {
+			return errors.Wrap(err, "unable to reset virtual machine")
+		}
+
+		return nil
+	})
 }
 
 // Suspend a virtual machine.
 func (p *Power) Suspend(ctx echo.Context) error {
-	err := p.performPowerAction(ctx, "suspend", virtualMachine{Name: ctx.Param("vm")})
+	vm, err := p.authorize(ctx, "suspend", ctx.Param("vm"))
+	if err != nil {
+		return errors.Wrap(err, "unable to suspend virtual machine")
+	}
+
+	err = p.limit(ctx, "suspend", vm)
 	if err != nil {
 		return errors.Wrap(err, "unable to suspend virtual machine")
 	}
 
-	return ctx.JSON(http.StatusOK, map[string]any{"result": "ok"})
+	return p.run(ctx, "suspend", vm, func(ctx echo.Context) error {
+		err := p.performPowerAction(ctx, "suspend", vm)
+		if err != nil {
+			return errors.Wrap(err, "unable to suspend virtual machine")
+		}
+
+		return nil
+	})
 }
 
 // getVirtualMachineByName get a virtualMachine by name.
@@ -103,7 +177,54 @@ func (p *Power) getVirtualMachineByName(ctx echo.Context, name string) (virtualM
 		}
 	}
 
-	return virtualMachine{}, errors.New("virtual machine %s not found", name)
+	return virtualMachine{}, errors.NewKind(errors.KindNotFound, "virtual machine %s not found", name)
+}
+
+// verifyPowerState check whether a power action took effect, actions which don't change the power state such as reset
+// can't be verified so they aren't retried.
+func (p *Power) verifyPowerState(ctx echo.Context, vm virtualMachine, action string) vsphere.Verify {
+	expected, ok := powerStates[action]
+	if !ok {
+		return nil
+	}
+
+	return func() (bool, error) {
+		response, err := p.vsphere.Request(ctx, http.MethodGet, fmt.Sprintf("/vcenter/vm/%s/power", vm.ID), nil)
+		if err != nil {
+			return false, errors.Wrap(err, "unable to fetch virtual machine power state")
+		}
+
+		var state powerState
+		err = json.Unmarshal(response, &state)
+		if err != nil {
+			return false, errors.Wrap(err, "unable to unmarshal virtual machine power state")
+		}
+
+		return state.State == expected, nil
+	}
+}
+
+// start power on a virtual machine once the power on queue admits it.
+func (p *Power) start(ctx echo.Context, vm virtualMachine) error {
+	job, _ := ctx.Get(jobKey).(string)
+
+	queued := p.admission.enqueue(vm.Name, job)
+	if position := p.admission.position(queued); position > 0 {
+		ctx.Response().Header().Set("X-Queue-Position", strconv.Itoa(position))
+
+		if p.notify != nil {
+			p.notify.Message(fmt.Sprintf("power on of virtual machine %s queued at position %d", vm.Name, position))
+		}
+	}
+
+	err := p.admission.wait(ctx.Request().Context(), queued)
+	if err != nil {
+		return err
+	}
+
+	defer p.admission.release()
+
+	return p.performPowerAction(ctx, "start", vm)
 }
 
 // performPowerAction perform a power action on a virtualMachine.
@@ -112,25 +233,26 @@ func (p *Power) performPowerAction(ctx echo.Context, action string, vm virtualMa
 		p.notify.Message(fmt.Sprintf("request received to %s virtual machine %s", action, vm.Name))
 	}
 
-	var err error
 	if vm.ID == "" {
-		vm, err = p.getVirtualMachineByName(ctx, vm.Name)
+		found, err := p.getVirtualMachineByName(ctx, vm.Name)
 		if err != nil {
 			if p.notify != nil {
 				p.notify.Message("unable to find virtual machine")
 			}
 
-			return errors.Wrap(err, "unable to find virtual machine")
+			return errors.With(errors.Wrap(err, "unable to find virtual machine"), "vm", vm.Name, "action", action)
 		}
+
+		vm = found
 	}
 
-	_, err = p.vsphere.Request(ctx, http.MethodPost, fmt.Sprintf("/vcenter/vm/%s/power?action=%s", vm.ID, action), nil)
+	_, err := p.vsphere.RequestVerified(ctx, http.MethodPost, fmt.Sprintf("/vcenter/vm/%s/power?action=%s", vm.ID, action), nil, p.verifyPowerState(ctx, vm, action))
 	if err != nil {
 		if p.notify != nil {
 			p.notify.Message(fmt.Sprintf("unable to %s virtual machine %s: %v", action, vm.Name, err))
 		}
 
-		return errors.Wrap(err, "unable to perform virtual machine power action")
+		return errors.With(errors.Wrap(err, "unable to perform virtual machine power action"), "vm", vm.Name, "action", action)
 	}
 
 	if p.notify != nil {
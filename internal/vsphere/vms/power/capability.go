@@ -0,0 +1,20 @@
+package power
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/sjdaws/vsphere-bridge/internal/vsphere"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+// supported reject requests if vsphere is too old to support the api used to manage virtual machines.
+func (p *Power) supported(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		err := p.vsphere.Require(ctx.Request().Context(), vsphere.CapabilityAPI)
+		if err != nil {
+			return errors.Wrap(err, "unable to manage virtual machine power")
+		}
+
+		return next(ctx)
+	}
+}
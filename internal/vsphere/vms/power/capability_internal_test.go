@@ -0,0 +1,99 @@
+package power
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/credentials"
+	"github.com/sjdaws/vsphere-bridge/internal/policy"
+	"github.com/sjdaws/vsphere-bridge/internal/problem"
+	"github.com/sjdaws/vsphere-bridge/internal/quota"
+	"github.com/sjdaws/vsphere-bridge/internal/vsphere"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+func TestPower_Supported(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		version  string
+		expected int
+	}{
+		"supported": {
+			version:  "8.0.2",
+			expected: http.StatusOK,
+		},
+		"unsupported": {
+			version:  "6.7.0",
+			expected: http.StatusNotImplemented,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			target := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+				switch {
+				case request.Method == http.MethodPost && request.URL.Path == "/api/session":
+					_, _ = io.WriteString(writer, `"token"`)
+				case request.URL.Path == "/api/appliance/system/version":
+					_, _ = fmt.Fprintf(writer, `{"version":%q,"build":"1"}`, testcase.version)
+				case request.URL.Path == "/api/vcenter/vm":
+					_, _ = io.WriteString(writer, `[{"vm":"vm-1","name":"vm1","power_state":"POWERED_OFF"}]`)
+				default:
+					writer.WriteHeader(http.StatusNoContent)
+				}
+			}))
+			t.Cleanup(target.Close)
+
+			server, err := url.Parse(target.URL)
+			require.NoError(t, err)
+
+			config := &configuration.Configuration{
+				HealthCheckInterval: time.Minute,
+				Password:            "password",
+				RetryAttempts:       1,
+				RetryTimeout:        5 * time.Second,
+				Server:              server,
+				Username:            "service",
+			}
+
+			logger, err := logging.New(logging.Error, io.Discard, 0)
+			require.NoError(t, err)
+
+			store, err := credentials.New(config, logger)
+			require.NoError(t, err)
+
+			api, err := vsphere.New(config, store, logger)
+			require.NoError(t, err)
+
+			limits, err := quota.New(config, logger)
+			require.NoError(t, err)
+
+			bridge := echo.New()
+			problem.New(config, logger).Attach(bridge)
+
+			rules, err := policy.New("", bridge)
+			require.NoError(t, err)
+
+			_, err = New(config, api, nil, rules, limits, bridge, nil)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			bridge.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/power/on/vm1", nil))
+
+			assert.Equal(t, testcase.expected, recorder.Code)
+		})
+	}
+}
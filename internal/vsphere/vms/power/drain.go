@@ -0,0 +1,51 @@
+package power
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+// Drain reject new requests and wait for in-flight requests to complete until the context is cancelled.
+func (p *Power) Drain(ctx context.Context) error {
+	p.mutex.Lock()
+	p.draining = true
+	p.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.inflight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.New("power operations still in progress")
+	}
+}
+
+// track in-flight requests so they can be drained before shutting down.
+func (p *Power) track(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		p.mutex.Lock()
+		if p.draining {
+			p.mutex.Unlock()
+
+			ctx.Response().Header().Set("Retry-After", "5")
+
+			return echo.NewHTTPError(http.StatusServiceUnavailable, "bridge is shutting down")
+		}
+
+		p.inflight.Add(1)
+		p.mutex.Unlock()
+
+		defer p.inflight.Done()
+
+		return next(ctx)
+	}
+}
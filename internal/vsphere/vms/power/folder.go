@@ -0,0 +1,109 @@
+package power
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+const (
+	// folderCacheTTL time the folder containing each virtual machine is cached for.
+	folderCacheTTL = 5 * time.Minute
+
+	// folderRefreshInterval minimum time between reloading folders to find a virtual machine which isn't cached.
+	folderRefreshInterval = 30 * time.Second
+)
+
+// folder api representation of a folder.
+type folder struct {
+	ID   string `json:"folder"`
+	Name string `json:"name"`
+}
+
+// folders cached names of the folders containing each virtual machine, keyed by virtual machine id.
+type folders struct {
+	loaded  time.Time
+	members map[string]string
+	mutex   sync.Mutex
+}
+
+// getVirtualMachineFolder get the name of the folder containing a virtualMachine.
+func (p *Power) getVirtualMachineFolder(ctx echo.Context, vm virtualMachine) (string, error) {
+	// vSphere can't look up the folder containing a virtual machine, so folder contents are cached rather than
+	// listing every folder for every action
+	p.folders.mutex.Lock()
+	age := time.Since(p.folders.loaded)
+	name, cached := p.folders.members[vm.ID]
+	stale := p.folders.members == nil || age >= folderCacheTTL || (!cached && age >= folderRefreshInterval)
+	p.folders.mutex.Unlock()
+
+	if !stale {
+		return name, nil
+	}
+
+	// Folders are listed without holding the lock so a slow vCenter doesn't block actions against cached
+	// virtual machines
+	started := time.Now()
+
+	members, err := p.listFolderMembers(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	p.folders.mutex.Lock()
+	defer p.folders.mutex.Unlock()
+
+	// Keep the newest listing if another request refreshed the cache while this one was loading
+	if started.After(p.folders.loaded) {
+		p.folders.loaded = started
+		p.folders.members = members
+	}
+
+	return members[vm.ID], nil
+}
+
+// listFolderMembers get the name of the folder containing each virtual machine.
+func (p *Power) listFolderMembers(ctx echo.Context) (map[string]string, error) {
+	response, err := p.vsphere.Request(ctx, http.MethodGet, "/vcenter/folder?type=VIRTUAL_MACHINE", nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch list of folders")
+	}
+
+	vmFolders := make([]folder, 0)
+	err = json.Unmarshal(response, &vmFolders)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal folder list")
+	}
+
+	members := make(map[string]string)
+
+	for _, vmFolder := range vmFolders {
+		query := url.Values{"folders": {vmFolder.ID}}
+
+		response, err = p.vsphere.Request(ctx, http.MethodGet, "/vcenter/vm?"+query.Encode(), nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to fetch virtual machines in folder %s", vmFolder.Name)
+		}
+
+		vms := make([]virtualMachine, 0)
+		err = json.Unmarshal(response, &vms)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to unmarshal virtual machine list")
+		}
+
+		// A virtual machine is attributed to the first folder it is listed in
+		for _, member := range vms {
+			if _, ok := members[member.ID]; !ok {
+				members[member.ID] = vmFolder.Name
+			}
+		}
+	}
+
+	return members, nil
+}
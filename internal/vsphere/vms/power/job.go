@@ -0,0 +1,221 @@
+package power
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sjdaws/vsphere-bridge/internal/identity"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+const (
+	// JobFailed the power action returned an error.
+	JobFailed = "failed"
+
+	// JobQueued the power action is waiting in the power on queue.
+	JobQueued = "queued"
+
+	// JobRunning the power action is being performed.
+	JobRunning = "running"
+
+	// JobSucceeded the power action completed.
+	JobSucceeded = "succeeded"
+
+	// jobKey key used to store the id of the job a detached request belongs to.
+	jobKey = "job"
+
+	// jobRetention how long finished jobs can be looked up for.
+	jobRetention = time.Hour
+)
+
+// job power action performed in the background for a caller which asked for an asynchronous response.
+type job struct {
+	Action   string     `json:"action"`
+	Error    string     `json:"error,omitempty"`
+	Finished *time.Time `json:"finished,omitempty"`
+	ID       string     `json:"id"`
+	Position int        `json:"position,omitempty"`
+	Started  time.Time  `json:"started"`
+	State    string     `json:"state"`
+	VM       string     `json:"vm"`
+}
+
+// jobs recently created jobs by id.
+type jobs struct {
+	entries map[string]*job
+	mutex   sync.Mutex
+}
+
+// discard response writer for requests which continue after the caller has been responded to.
+type discard struct {
+	header http.Header
+}
+
+// Job get the state of a power action performed in the background.
+func (p *Power) Job(ctx echo.Context) error {
+	current, ok := p.jobs.get(ctx.Param("id"))
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "job not found")
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]any{"result": "ok", "job": p.snapshot(current)})
+}
+
+// run perform a power action, in the background if the caller prefers an asynchronous response.
+func (p *Power) run(ctx echo.Context, action string, vm virtualMachine, perform func(ctx echo.Context) error) error {
+	if !asynchronous(ctx) {
+		err := perform(ctx)
+		if err != nil {
+			return err
+		}
+
+		return ctx.JSON(http.StatusOK, map[string]any{"result": "ok"})
+	}
+
+	created, err := p.jobs.create(action, vm.Name)
+	if err != nil {
+		return errors.Wrap(err, "unable to create job")
+	}
+
+	detached := detach(ctx)
+	detached.Set(jobKey, created.ID)
+
+	// The caller is already being tracked so the job can't start after draining has finished
+	p.inflight.Add(1)
+	go func() {
+		defer p.inflight.Done()
+
+		p.jobs.finish(created.ID, perform(detached))
+	}()
+
+	ctx.Response().Header().Set(echo.HeaderLocation, "/power/jobs/"+created.ID)
+
+	return ctx.JSON(http.StatusAccepted, map[string]any{"result": "accepted", "job": p.snapshot(created)})
+}
+
+// snapshot copy a job including its current position in the power on queue.
+func (p *Power) snapshot(current job) job {
+	if current.State == JobRunning {
+		current.Position = p.admission.positionOf(current.ID)
+		if current.Position > 0 {
+			current.State = JobQueued
+		}
+	}
+
+	return current
+}
+
+// asynchronous determine whether the caller asked for an asynchronous response.
+func asynchronous(ctx echo.Context) bool {
+	if _, ok := ctx.QueryParams()["async"]; ok {
+		return true
+	}
+
+	for _, preference := range strings.Split(ctx.Request().Header.Get("Prefer"), ",") {
+		if strings.EqualFold(strings.TrimSpace(preference), "respond-async") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// detach copy a request into a context which outlives the request so a job can keep using it. Values set on the
+// original context aren't copied, so the caller's identity is set again for anything the job logs or authorizes.
+func detach(ctx echo.Context) echo.Context {
+	request := ctx.Request().Clone(context.WithoutCancel(ctx.Request().Context()))
+
+	detached := ctx.Echo().NewContext(request, &discard{header: make(http.Header)})
+	identity.Set(detached, identity.FromContext(ctx))
+
+	return detached
+}
+
+// create a job which is running.
+func (j *jobs) create(action string, vm string) (job, error) {
+	id := make([]byte, 16)
+
+	_, err := rand.Read(id)
+	if err != nil {
+		return job{}, errors.Wrap(err, "unable to generate job id")
+	}
+
+	created := &job{
+		Action:  action,
+		ID:      hex.EncodeToString(id),
+		Started: time.Now().UTC(),
+		State:   JobRunning,
+		VM:      vm,
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.entries == nil {
+		j.entries = make(map[string]*job)
+	}
+
+	// Forget jobs which finished a while ago
+	for id, existing := range j.entries {
+		if existing.Finished != nil && time.Since(*existing.Finished) > jobRetention {
+			delete(j.entries, id)
+		}
+	}
+
+	j.entries[created.ID] = created
+
+	return *created, nil
+}
+
+// finish record the result of a job.
+func (j *jobs) finish(id string, err error) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	finished, ok := j.entries[id]
+	if !ok {
+		return
+	}
+
+	now := time.Now().UTC()
+	finished.Finished = &now
+	finished.State = JobSucceeded
+
+	if err != nil {
+		finished.Error = errors.Detail(err)
+		finished.State = JobFailed
+	}
+}
+
+// get a copy of a job.
+func (j *jobs) get(id string) (job, bool) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	found, ok := j.entries[id]
+	if !ok {
+		return job{}, false
+	}
+
+	return *found, true
+}
+
+// Header returns the headers which are discarded.
+func (d *discard) Header() http.Header {
+	return d.header
+}
+
+// Write discard a response body.
+func (d *discard) Write(body []byte) (int, error) {
+	return len(body), nil
+}
+
+// WriteHeader discard a response status.
+func (d *discard) WriteHeader(int) {}
@@ -0,0 +1,200 @@
+package power
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/identity"
+)
+
+// send a request to the bridge.
+func send(bridge *echo.Echo, method string, target string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	bridge.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+
+	return recorder
+}
+
+// lookup the current state of a job.
+func lookup(t *testing.T, bridge *echo.Echo, location string) job {
+	t.Helper()
+
+	recorder := send(bridge, http.MethodGet, location)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var response struct {
+		Job job `json:"job"`
+	}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+	return response.Job
+}
+
+func TestPower_Job(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		target string
+		state  string
+		error  bool
+	}{
+		"succeeded": {
+			target: "/power/on/web1?async",
+			state:  JobSucceeded,
+		},
+		"failed": {
+			target: "/power/off/loose1?async",
+			state:  JobFailed,
+			error:  true,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, bridge := newTestPower(t, &fakeVcenter{}, "", nil)
+
+			recorder := send(bridge, http.MethodPost, testcase.target)
+			require.Equal(t, http.StatusAccepted, recorder.Code)
+
+			location := recorder.Header().Get(echo.HeaderLocation)
+			require.NotEmpty(t, location)
+
+			var finished job
+			require.Eventually(t, func() bool {
+				finished = lookup(t, bridge, location)
+
+				return finished.Finished != nil
+			}, 5*time.Second, 10*time.Millisecond)
+
+			assert.Equal(t, testcase.state, finished.State)
+			assert.Equal(t, testcase.error, finished.Error != "")
+		})
+	}
+}
+
+func TestPower_JobQueued(t *testing.T) {
+	t.Parallel()
+
+	power, bridge := newTestPower(t, &fakeVcenter{}, "", func(config *configuration.Configuration) {
+		config.PowerOnConcurrency = 1
+	})
+
+	// Hold the only slot so the job has to wait in the queue
+	holder := power.admission.enqueue("other", "")
+	require.NoError(t, power.admission.wait(context.Background(), holder))
+
+	request := httptest.NewRequest(http.MethodPost, "/power/on/web1", nil)
+	request.Header.Set("Prefer", "respond-async")
+
+	recorder := httptest.NewRecorder()
+	bridge.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusAccepted, recorder.Code)
+
+	location := recorder.Header().Get(echo.HeaderLocation)
+
+	var queued job
+	require.Eventually(t, func() bool {
+		queued = lookup(t, bridge, location)
+
+		return queued.State == JobQueued
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, "on", queued.Action)
+	assert.Equal(t, "web1", queued.VM)
+	assert.Equal(t, 1, queued.Position)
+	assert.Nil(t, queued.Finished)
+
+	power.admission.release()
+
+	require.Eventually(t, func() bool {
+		return lookup(t, bridge, location).State == JobSucceeded
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestPower_JobNotFound(t *testing.T) {
+	t.Parallel()
+
+	_, bridge := newTestPower(t, &fakeVcenter{}, "", nil)
+
+	assert.Equal(t, http.StatusNotFound, send(bridge, http.MethodGet, "/power/jobs/missing").Code)
+}
+
+func TestPower_Rejected(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		limits     string
+		drain      bool
+		status     int
+		retryAfter string
+	}{
+		"allowed": {
+			status: http.StatusOK,
+		},
+		"power limit reached": {
+			limits:     "client:on=1/1h",
+			status:     http.StatusTooManyRequests,
+			retryAfter: "3600",
+		},
+		"draining": {
+			drain:      true,
+			status:     http.StatusServiceUnavailable,
+			retryAfter: "5",
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			power, bridge := newTestPower(t, &fakeVcenter{}, "", func(config *configuration.Configuration) {
+				config.PowerLimits = testcase.limits
+			})
+
+			require.Equal(t, http.StatusOK, send(bridge, http.MethodPost, "/power/on/web1").Code)
+
+			if testcase.drain {
+				require.NoError(t, power.Drain(context.Background()))
+			}
+
+			recorder := send(bridge, http.MethodPost, "/power/on/web1")
+			assert.Equal(t, testcase.status, recorder.Code)
+			assert.Equal(t, testcase.retryAfter, recorder.Header().Get("Retry-After"))
+		})
+	}
+}
+
+func TestDetach(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	request := httptest.NewRequest(http.MethodPost, "/power/on/web1?async", nil).WithContext(ctx)
+	request.Header.Set("Authorization", "Bearer token")
+
+	original := echo.New().NewContext(request, httptest.NewRecorder())
+	client := identity.Identity{Method: "oidc", Name: "ci", Roles: []string{"operators"}}
+	identity.Set(original, client)
+
+	detached := detach(original)
+	cancel()
+
+	assert.Equal(t, client, identity.FromContext(detached))
+	assert.Equal(t, "Bearer token", detached.Request().Header.Get("Authorization"))
+	require.NoError(t, detached.Request().Context().Err())
+
+	// Responses written by the job don't reach the caller
+	require.NoError(t, detached.JSON(http.StatusOK, map[string]string{"result": "ok"}))
+	assert.False(t, original.Response().Committed)
+}
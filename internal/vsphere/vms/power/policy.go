@@ -0,0 +1,119 @@
+package power
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sjdaws/vsphere-bridge/internal/identity"
+	"github.com/sjdaws/vsphere-bridge/internal/policy"
+	"github.com/sjdaws/vsphere-bridge/internal/vsphere"
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+// tag api representation of a tag.
+type tag struct {
+	Name string `json:"name"`
+}
+
+// authorize evaluate the policy for an action against a virtual machine.
+func (p *Power) authorize(ctx echo.Context, action string, name string) (virtualMachine, error) {
+	if !p.policy.Enabled() {
+		return virtualMachine{Name: name}, nil
+	}
+
+	vm, err := p.getVirtualMachineByName(ctx, name)
+	if err != nil {
+		return virtualMachine{}, errors.Wrap(err, "unable to find virtual machine")
+	}
+
+	folderName, err := p.getVirtualMachineFolder(ctx, vm)
+	if err != nil {
+		return virtualMachine{}, errors.Wrap(err, "unable to determine virtual machine folder")
+	}
+
+	tags, err := p.getVirtualMachineTags(ctx, vm)
+	if err != nil {
+		return virtualMachine{}, errors.Wrap(err, "unable to determine virtual machine tags")
+	}
+
+	decision, err := p.policy.Authorize(policy.Attributes{
+		Action:   action,
+		Client:   identity.FromContext(ctx),
+		SourceIP: ctx.RealIP(),
+		VM: policy.VirtualMachine{
+			Folder: folderName,
+			ID:     vm.ID,
+			Name:   vm.Name,
+			Tags:   tags,
+		},
+	})
+	if err != nil {
+		return virtualMachine{}, errors.Wrap(err, "unable to evaluate policy")
+	}
+
+	if !decision.Allowed {
+		message := fmt.Sprintf("request to %s virtual machine %s denied by policy", action, vm.Name)
+		if decision.Rule != "" {
+			message = fmt.Sprintf("%s rule %s", message, decision.Rule)
+		}
+
+		if decision.Message != "" {
+			message = fmt.Sprintf("%s: %s", message, decision.Message)
+		}
+
+		if p.notify != nil {
+			p.notify.Message(message)
+		}
+
+		return virtualMachine{}, echo.NewHTTPError(http.StatusForbidden, message)
+	}
+
+	return vm, nil
+}
+
+// getVirtualMachineTags get the names of tags attached to a virtualMachine.
+func (p *Power) getVirtualMachineTags(ctx echo.Context, vm virtualMachine) ([]string, error) {
+	err := p.vsphere.Require(ctx.Request().Context(), vsphere.CapabilityTagging)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch attached tags")
+	}
+
+	payload, err := json.Marshal(map[string]any{"object_id": map[string]string{"id": vm.ID, "type": "VirtualMachine"}})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal tag association request")
+	}
+
+	response, err := p.vsphere.Request(ctx, http.MethodPost, "/cis/tagging/tag-association?action=list-attached-tags", bytes.NewReader(payload))
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch attached tags")
+	}
+
+	ids := make([]string, 0)
+	err = json.Unmarshal(response, &ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal attached tags")
+	}
+
+	tags := make([]string, 0, len(ids))
+	for _, id := range ids {
+		response, err = p.vsphere.Request(ctx, http.MethodGet, "/cis/tagging/tag/"+url.PathEscape(id), nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to fetch tag %s", id)
+		}
+
+		var vmTag tag
+		err = json.Unmarshal(response, &vmTag)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to unmarshal tag %s", id)
+		}
+
+		tags = append(tags, vmTag.Name)
+	}
+
+	return tags, nil
+}
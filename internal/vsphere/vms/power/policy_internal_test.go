@@ -0,0 +1,346 @@
+package power
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/credentials"
+	"github.com/sjdaws/vsphere-bridge/internal/identity"
+	"github.com/sjdaws/vsphere-bridge/internal/policy"
+	"github.com/sjdaws/vsphere-bridge/internal/problem"
+	"github.com/sjdaws/vsphere-bridge/internal/quota"
+	"github.com/sjdaws/vsphere-bridge/internal/vsphere"
+	"github.com/sjdaws/vsphere-bridge/pkg/logging"
+)
+
+// fakeVcenter vCenter api serving a fixed inventory of virtual machines, folders and tags.
+type fakeVcenter struct {
+	block       chan struct{}
+	folderLists atomic.Int32
+}
+
+// ServeHTTP respond to the requests made by power actions.
+func (f *fakeVcenter) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	switch {
+	case request.Method == http.MethodPost && request.URL.Path == "/api/session":
+		_, _ = io.WriteString(writer, `"token"`)
+	case request.URL.Path == "/api/appliance/system/version":
+		_, _ = io.WriteString(writer, `{"version":"8.0.2","build":"1"}`)
+	case request.URL.Path == "/api/vcenter/folder":
+		f.folderLists.Add(1)
+
+		if f.block != nil {
+			<-f.block
+		}
+
+		_, _ = io.WriteString(writer, `[{"folder":"group-v1","name":"web"},{"folder":"group-v2","name":"prod"}]`)
+	case request.URL.Path == "/api/vcenter/vm":
+		switch request.URL.Query().Get("folders") {
+		case "group-v1":
+			_, _ = io.WriteString(writer, `[{"vm":"vm-1","name":"web1"}]`)
+		case "group-v2":
+			// web1 is also listed in prod, but is attributed to the first folder it was found in
+			_, _ = io.WriteString(writer, `[{"vm":"vm-1","name":"web1"},{"vm":"vm-3","name":"db1"},{"vm":"vm-4","name":"app1"}]`)
+		default:
+			_, _ = io.WriteString(writer, `[{"vm":"vm-1","name":"web1"},{"vm":"vm-2","name":"loose1"},{"vm":"vm-3","name":"db1"},{"vm":"vm-4","name":"app1"}]`)
+		}
+	case request.Method == http.MethodPost && request.URL.Path == "/api/vcenter/vm/vm-2/power":
+		writer.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(writer, `{"error_type":"ALREADY_IN_DESIRED_STATE"}`)
+	case request.URL.Path == "/api/cis/tagging/tag-association":
+		var body struct {
+			ObjectID struct {
+				ID string `json:"id"`
+			} `json:"object_id"`
+		}
+		_ = json.NewDecoder(request.Body).Decode(&body)
+
+		if body.ObjectID.ID == "vm-3" {
+			_, _ = io.WriteString(writer, `["urn:tag:1","urn:tag:2"]`)
+
+			return
+		}
+
+		_, _ = io.WriteString(writer, `[]`)
+	case request.URL.Path == "/api/cis/tagging/tag/urn:tag:1":
+		_, _ = io.WriteString(writer, `{"name":"database"}`)
+	case request.URL.Path == "/api/cis/tagging/tag/urn:tag:2":
+		_, _ = io.WriteString(writer, `{"name":"protected"}`)
+	default:
+		writer.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// newTestPower create a power instance which sends requests to a fake vCenter.
+func newTestPower(t *testing.T, target http.Handler, policyFile string, changes func(config *configuration.Configuration)) (*Power, *echo.Echo) {
+	t.Helper()
+
+	server := httptest.NewServer(target)
+	t.Cleanup(server.Close)
+
+	address, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	config := &configuration.Configuration{
+		HealthCheckInterval: time.Minute,
+		Password:            "password",
+		RetryAttempts:       1,
+		RetryTimeout:        5 * time.Second,
+		Server:              address,
+		Username:            "service",
+	}
+
+	if changes != nil {
+		changes(config)
+	}
+
+	logger, err := logging.New(logging.Error, io.Discard, 0)
+	require.NoError(t, err)
+
+	store, err := credentials.New(config, logger)
+	require.NoError(t, err)
+
+	api, err := vsphere.New(config, store, logger)
+	require.NoError(t, err)
+
+	limits, err := quota.New(config, logger)
+	require.NoError(t, err)
+
+	bridge := echo.New()
+	problem.New(config, logger).Attach(bridge)
+
+	rules, err := policy.New(policyFile, bridge)
+	require.NoError(t, err)
+
+	power, err := New(config, api, nil, rules, limits, bridge, nil)
+	require.NoError(t, err)
+
+	return power, bridge
+}
+
+// newTestContext create a context for a request made by a client.
+func newTestContext(bridge *echo.Echo, client identity.Identity) echo.Context {
+	ctx := bridge.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+	identity.Set(ctx, client)
+
+	return ctx
+}
+
+func TestPower_Authorize(t *testing.T) {
+	t.Parallel()
+
+	policyFile := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(policyFile, []byte(`
+default: allow
+rules:
+  - name: protected
+    condition: '"protected" in vm.tags'
+    effect: deny
+    message: ask a dba
+  - name: production
+    condition: 'vm.folder == "prod" && !("oncall" in client.roles)'
+    effect: deny
+  - name: unfiled
+    condition: 'vm.folder == "" && action == "off"'
+    effect: deny
+`), 0o600))
+
+	power, bridge := newTestPower(t, &fakeVcenter{}, policyFile, nil)
+
+	oncall := identity.Identity{Method: "oidc", Name: "bob", Roles: []string{"oncall"}}
+
+	testcases := map[string]struct {
+		action   string
+		client   identity.Identity
+		vm       string
+		expected string
+		status   int
+	}{
+		"allowed": {
+			action:   "on",
+			client:   identity.Anonymous,
+			vm:       "web1",
+			expected: "vm-1",
+		},
+		"denied by tag": {
+			action: "on",
+			client: oncall,
+			vm:     "db1",
+			status: http.StatusForbidden,
+		},
+		"denied by folder": {
+			action: "on",
+			client: identity.Anonymous,
+			vm:     "app1",
+			status: http.StatusForbidden,
+		},
+		"folder allowed for role": {
+			action:   "on",
+			client:   oncall,
+			vm:       "app1",
+			expected: "vm-4",
+		},
+		"first folder wins": {
+			action:   "off",
+			client:   identity.Anonymous,
+			vm:       "web1",
+			expected: "vm-1",
+		},
+		"not in a folder": {
+			action: "off",
+			client: identity.Anonymous,
+			vm:     "loose1",
+			status: http.StatusForbidden,
+		},
+		"not in a folder for another action": {
+			action:   "on",
+			client:   identity.Anonymous,
+			vm:       "loose1",
+			expected: "vm-2",
+		},
+		"not found": {
+			action: "on",
+			client: identity.Anonymous,
+			vm:     "missing",
+			status: http.StatusNotFound,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			vm, err := power.authorize(newTestContext(bridge, testcase.client), testcase.action, testcase.vm)
+			if testcase.status != 0 {
+				require.Error(t, err)
+
+				recorder := httptest.NewRecorder()
+				bridge.HTTPErrorHandler(err, bridge.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), recorder))
+				assert.Equal(t, testcase.status, recorder.Code)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testcase.expected, vm.ID)
+		})
+	}
+}
+
+func TestPower_AuthorizeMessage(t *testing.T) {
+	t.Parallel()
+
+	policyFile := filepath.Join(t.TempDir(), "policy.yaml")
+	require.NoError(t, os.WriteFile(policyFile, []byte(`
+rules:
+  - name: protected
+    condition: '"protected" in vm.tags'
+    effect: deny
+    message: ask a dba
+`), 0o600))
+
+	power, bridge := newTestPower(t, &fakeVcenter{}, policyFile, nil)
+
+	_, err := power.authorize(newTestContext(bridge, identity.Anonymous), "reset", "db1")
+
+	var httpError *echo.HTTPError
+	require.ErrorAs(t, err, &httpError)
+	assert.Equal(t, http.StatusForbidden, httpError.Code)
+	assert.Equal(t, "request to reset virtual machine db1 denied by policy rule protected: ask a dba", httpError.Message)
+}
+
+func TestPower_GetVirtualMachineFolder(t *testing.T) {
+	t.Parallel()
+
+	vcenter := &fakeVcenter{}
+	power, bridge := newTestPower(t, vcenter, "", nil)
+	ctx := newTestContext(bridge, identity.Anonymous)
+
+	// Folders are listed once and cached
+	folderName, err := power.getVirtualMachineFolder(ctx, virtualMachine{ID: "vm-4"})
+	require.NoError(t, err)
+	assert.Equal(t, "prod", folderName)
+
+	folderName, err = power.getVirtualMachineFolder(ctx, virtualMachine{ID: "vm-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "web", folderName)
+	assert.Equal(t, int32(1), vcenter.folderLists.Load())
+
+	// A virtual machine which isn't in a folder doesn't reload folders more often than the refresh interval
+	folderName, err = power.getVirtualMachineFolder(ctx, virtualMachine{ID: "vm-2"})
+	require.NoError(t, err)
+	assert.Empty(t, folderName)
+	assert.Equal(t, int32(1), vcenter.folderLists.Load())
+
+	power.folders.mutex.Lock()
+	power.folders.loaded = time.Now().Add(-folderRefreshInterval)
+	power.folders.mutex.Unlock()
+
+	_, err = power.getVirtualMachineFolder(ctx, virtualMachine{ID: "vm-2"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), vcenter.folderLists.Load())
+
+	// Cached virtual machines are reloaded once the cache expires
+	_, err = power.getVirtualMachineFolder(ctx, virtualMachine{ID: "vm-1"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), vcenter.folderLists.Load())
+
+	power.folders.mutex.Lock()
+	power.folders.loaded = time.Now().Add(-folderCacheTTL)
+	power.folders.mutex.Unlock()
+
+	_, err = power.getVirtualMachineFolder(ctx, virtualMachine{ID: "vm-1"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), vcenter.folderLists.Load())
+}
+
+func TestPower_GetVirtualMachineFolderWhileRefreshing(t *testing.T) {
+	t.Parallel()
+
+	vcenter := &fakeVcenter{block: make(chan struct{})}
+	power, bridge := newTestPower(t, vcenter, "", nil)
+
+	power.folders.loaded = time.Now().Add(-folderRefreshInterval)
+	power.folders.members = map[string]string{"vm-1": "web"}
+
+	var refreshing sync.WaitGroup
+
+	refreshing.Add(1)
+
+	go func() {
+		defer refreshing.Done()
+
+		folderName, err := power.getVirtualMachineFolder(newTestContext(bridge, identity.Anonymous), virtualMachine{ID: "vm-4"})
+		assert.NoError(t, err)
+		assert.Equal(t, "prod", folderName)
+	}()
+
+	require.Eventually(t, func() bool { return vcenter.folderLists.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// Cached virtual machines are still found while folders are being listed
+	folderName, err := power.getVirtualMachineFolder(newTestContext(bridge, identity.Anonymous), virtualMachine{ID: "vm-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "web", folderName)
+
+	close(vcenter.block)
+	refreshing.Wait()
+
+	power.folders.mutex.Lock()
+	defer power.folders.mutex.Unlock()
+
+	assert.Equal(t, map[string]string{"vm-1": "web", "vm-3": "prod", "vm-4": "prod"}, power.folders.members)
+}
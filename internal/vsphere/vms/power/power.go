@@ -1,31 +1,75 @@
 package power
 
 import (
+	"sync"
+
 	"github.com/labstack/echo/v4"
 
+	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/policy"
+	"github.com/sjdaws/vsphere-bridge/internal/quota"
 	"github.com/sjdaws/vsphere-bridge/internal/vsphere"
 	"github.com/sjdaws/vsphere-bridge/pkg/notifier"
 )
 
 type Power struct {
-	notify  *notifier.Notifier
-	vsphere vsphere.Vsphere
+	admission admission
+	draining  bool
+	folders   folders
+	inflight  sync.WaitGroup
+	jobs      jobs
+	limits    *quota.Quota
+	mutex     sync.Mutex
+	notify    *notifier.Notifier
+	policy    *policy.Policy
+	vsphere   *vsphere.Vsphere
 }
 
 // New create a new power instance.
-func New(vsphere vsphere.Vsphere, notify *notifier.Notifier, server *echo.Echo) *Power {
+func New(config *configuration.Configuration, vsphere *vsphere.Vsphere, notify *notifier.Notifier, rules *policy.Policy, limits *quota.Quota, server *echo.Echo, signed echo.MiddlewareFunc, middleware ...echo.MiddlewareFunc) (*Power, error) {
 	api := &Power{
+		limits:  limits,
 		notify:  notify,
+		policy:  rules,
 		vsphere: vsphere,
 	}
 
-	group := server.Group("/power")
+	apply, err := api.Prepare(config)
+	if err != nil {
+		return nil, err
+	}
+
+	apply()
+
+	group := server.Group("/power", append(middleware, api.track, api.supported)...)
 	group.GET("/:vm", api.Get)
+	group.GET("/jobs/:id", api.Job)
 	group.POST("/cycle/:vm", api.Cycle)
 	group.POST("/off/:vm", api.Off)
 	group.POST("/on/:vm", api.On)
 	group.POST("/reset/:vm", api.Reset)
 	group.POST("/suspend/:vm", api.Suspend)
 
-	return api
+	// Pre-signed urls allow actions to be performed by tools which can only send GET requests
+	if signed != nil {
+		group.GET("/cycle/:vm", api.Cycle, signed)
+		group.GET("/off/:vm", api.Off, signed)
+		group.GET("/on/:vm", api.On, signed)
+		group.GET("/reset/:vm", api.Reset, signed)
+		group.GET("/suspend/:vm", api.Suspend, signed)
+	}
+
+	return api, nil
+}
+
+// Prepare parse power on queue settings from a configuration and return a function which applies them.
+func (p *Power) Prepare(config *configuration.Configuration) (func(), error) {
+	priorities, err := parsePriorities(config.PowerOnPriorities)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		p.admission.configure(config.PowerOnConcurrency, config.PowerOnStagger, priorities)
+	}, nil
 }
@@ -0,0 +1,20 @@
+package power
+
+import (
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/sjdaws/vsphere-bridge/internal/quota"
+)
+
+// limit count an authorized action against the client and the virtual machine it targets, rejecting it once a limit
+// is reached.
+func (p *Power) limit(ctx echo.Context, action string, vm virtualMachine) error {
+	err := p.limits.Take(ctx, action, quota.Client(ctx), vm.Name)
+	if err != nil && p.notify != nil {
+		p.notify.Message(fmt.Sprintf("request to %s virtual machine %s rejected by power limits", action, vm.Name))
+	}
+
+	return err
+}
@@ -1,21 +1,74 @@
 package vsphere
 
 import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
 	"github.com/sjdaws/vsphere-bridge/internal/configuration"
+	"github.com/sjdaws/vsphere-bridge/internal/credentials"
 	"github.com/sjdaws/vsphere-bridge/pkg/logging"
 )
 
 // Vsphere instance of vsphere.
 type Vsphere struct {
-	config *configuration.Configuration
-	logger logging.Logger
-	token  string
+	about       *About
+	breakers    *breakers
+	client      atomic.Pointer[http.Client]
+	config      atomic.Pointer[configuration.Configuration]
+	credentials *credentials.Store
+	discovery   sync.Mutex
+	health      sync.Mutex
+	limiter     *limiter
+	lockout     *lockout
+	logger      logging.Logger
+	service     *session
+	sessions    sync.Mutex
+	status      *Status
 }
 
 // New create a new Vsphere instance.
-func New(config *configuration.Configuration, logger logging.Logger) *Vsphere {
-	return &Vsphere{
-		config: config,
-		logger: logger,
+func New(config *configuration.Configuration, store *credentials.Store, logger logging.Logger) (*Vsphere, error) {
+	api := &Vsphere{
+		breakers:    newBreakers(config.BreakerThreshold, config.BreakerCooldown),
+		credentials: store,
+		limiter:     newLimiter(config.VsphereRateLimit, config.VsphereRateBurst, config.VsphereMaxConcurrency),
+		lockout:     newLockout(config.LockoutThreshold, config.LockoutDuration, config.LockoutMaxDuration),
+		logger:      logger,
+	}
+
+	client, err := newClient(config)
+	if err != nil {
+		return nil, err
 	}
+
+	api.client.Store(client)
+	api.config.Store(config)
+
+	// Sessions created with previous credentials must not be reused
+	store.OnChange(api.invalidate)
+
+	return api, nil
+}
+
+// Prepare return a function which applies a configuration.
+func (v *Vsphere) Prepare(config *configuration.Configuration) (func(), error) {
+	client, err := newClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		// Sessions belong to the server they were created on
+		previous := v.config.Load()
+		if previous.Server.String() != config.Server.String() || previous.Insecure != config.Insecure {
+			v.invalidate()
+		}
+
+		v.client.Store(client)
+		v.config.Store(config)
+		v.breakers.configure(config.BreakerThreshold, config.BreakerCooldown)
+		v.limiter.configure(config.VsphereRateLimit, config.VsphereRateBurst, config.VsphereMaxConcurrency)
+		v.lockout.configure(config.LockoutThreshold, config.LockoutDuration, config.LockoutMaxDuration)
+	}, nil
 }
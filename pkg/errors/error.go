@@ -9,7 +9,9 @@ import (
 
 // Error root error type.
 type Error struct {
+	fields   *[]Field
 	file     string
+	kind     Kind
 	line     int
 	message  string
 	previous error
@@ -46,26 +48,61 @@ func (e Error) Error() string {
 		return e.message
 	}
 
+	// Errors which only attach fields don't add context
+	if e.message == "" {
+		return e.previous.Error()
+	}
+
 	return fmt.Sprintf("%s: %s", e.message, original.Error())
 }
 
 // Trace returns an error message with caller information.
 func (e Error) Trace() string {
-	stack := make([]string, 0)
+	return fmt.Sprintf("%s\n- %s", e.Error(), strings.Join(e.stack(), "\n- "))
+}
 
-	err := e
-	previous := true
+// stack of caller information and messages for an error and each error it wraps, joined errors are numbered.
+func (e Error) stack() []string {
+	frame := make([]string, 0, 3)
+	if e.file != "" {
+		frame = append(frame, fmt.Sprintf("%s:%d:", e.file, e.line))
+	}
 
-	for previous {
-		var line string
-		if err.file != "" {
-			line = fmt.Sprintf("%s:%d: ", err.file, err.line)
+	for _, part := range []string{e.message, formatFields(e.Fields())} {
+		if part != "" {
+			frame = append(frame, part)
 		}
+	}
 
-		stack = append(stack, fmt.Sprintf("%s%s", line, err.message))
+	stack := []string{strings.Join(frame, " ")}
 
-		previous = errors.As(err.previous, &err)
+	for next := e.previous; next != nil; next = errors.Unwrap(next) {
+		switch typed := next.(type) {
+		case Error:
+			return append(stack, typed.stack()...)
+		case multiple:
+			return append(stack, stackOf(typed)...)
+		}
 	}
 
-	return fmt.Sprintf("%s\n- %s", e.Error(), strings.Join(stack, "\n- "))
+	return stack
+}
+
+// stackOf an error which may not be an Error.
+func stackOf(err error) []string {
+	switch typed := err.(type) {
+	case Error:
+		return typed.stack()
+	case multiple:
+		stack := make([]string, 0)
+		for index, item := range typed.Unwrap() {
+			for _, frame := range stackOf(item) {
+				stack = append(stack, fmt.Sprintf("[%d] %s", index, frame))
+			}
+		}
+
+		return stack
+	default:
+		return []string{err.Error()}
+	}
 }
@@ -0,0 +1,84 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Field key value pair attached to an error.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// FieldsOf returns the fields attached to an error and each error it wraps, fields closer to the outermost error take
+// precedence. Fields attached to joined errors are specific to each error so aren't included.
+func FieldsOf(err error) map[string]any {
+	fields := make(map[string]any)
+
+	for next := err; next != nil; next = errors.Unwrap(next) {
+		typed, ok := next.(Error)
+		if !ok {
+			continue
+		}
+
+		for _, field := range typed.Fields() {
+			if _, exists := fields[field.Key]; !exists {
+				fields[field.Key] = field.Value
+			}
+		}
+	}
+
+	return fields
+}
+
+// With wraps an error with key value pairs, e.g. With(err, "vm", name, "action", action).
+func With(err error, keyValues ...any) error {
+	if err == nil {
+		return nil
+	}
+
+	_, file, line, _ := runtime.Caller(1)
+
+	fields := make([]Field, 0, len(keyValues)/2+1)
+	for index := 0; index < len(keyValues); index += 2 {
+		field := Field{Key: fmt.Sprintf("%v", keyValues[index])}
+		if index+1 < len(keyValues) {
+			field.Value = keyValues[index+1]
+		}
+
+		fields = append(fields, field)
+	}
+
+	return Error{
+		fields:   &fields,
+		file:     file,
+		line:     line,
+		previous: err,
+	}
+}
+
+// Fields returns the fields attached to an error, excluding fields attached to errors it wraps.
+func (e Error) Fields() []Field {
+	if e.fields == nil {
+		return nil
+	}
+
+	return *e.fields
+}
+
+// formatFields as space separated key=value pairs.
+func formatFields(fields []Field) string {
+	if len(fields) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(fields))
+	for _, field := range fields {
+		pairs = append(pairs, fmt.Sprintf("%s=%v", field.Key, field.Value))
+	}
+
+	return "[" + strings.Join(pairs, " ") + "]"
+}
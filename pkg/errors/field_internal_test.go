@@ -0,0 +1,42 @@
+package errors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_formatFields(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", formatFields(nil))
+	assert.Equal(t, "[vm=vm01 action=on]", formatFields([]Field{{Key: "vm", Value: "vm01"}, {Key: "action", Value: "on"}}))
+}
+
+func TestError_stack(t *testing.T) {
+	t.Parallel()
+
+	err := Error{
+		fields:  &[]Field{{Key: "action", Value: "on"}},
+		file:    "file",
+		line:    1,
+		message: "an error",
+		previous: Errors{
+			Error{
+				fields:   &[]Field{{Key: "vm", Value: "vm01"}},
+				file:     "file",
+				line:     2,
+				message:  "first error",
+				previous: nil,
+			},
+			errWrapperStdlib,
+		},
+	}
+	expected := []string{
+		"file:1: an error [action=on]",
+		"[0] file:2: first error [vm=vm01]",
+		"[1] original error",
+	}
+
+	assert.Equal(t, expected, err.stack())
+}
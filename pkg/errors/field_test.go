@@ -0,0 +1,55 @@
+package errors_test
+
+import (
+	errs "errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+func TestWith(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, errors.With(nil, "vm", "vm01"))
+
+	err := errors.With(errors.Wrap(errPackage, "some context"), "vm", "vm01", "action", "on", "dangling")
+	assert.Equal(t, "some context: original error", err.Error())
+	assert.True(t, errors.Is(err, errPackage))
+
+	var withFields errors.Error
+	require.True(t, errors.As(err, &withFields))
+	assert.Equal(t, []errors.Field{{Key: "vm", Value: "vm01"}, {Key: "action", Value: "on"}, {Key: "dangling"}}, withFields.Fields())
+
+	err = errors.With(errStdlib, "vm", "vm01")
+	assert.Equal(t, "original error", err.Error())
+	assert.True(t, errs.Is(err, errStdlib))
+}
+
+func TestFieldsOf(t *testing.T) {
+	t.Parallel()
+
+	assert.Empty(t, errors.FieldsOf(nil))
+	assert.Empty(t, errors.FieldsOf(errStdlib))
+
+	inner := errors.With(errors.New("an error"), "vm", "vm01", "target", "inner")
+	outer := errors.With(errors.Wrap(inner, "some context"), "target", "outer")
+
+	assert.Equal(t, map[string]any{"target": "outer", "vm": "vm01"}, errors.FieldsOf(outer))
+
+	joined := errors.Join(errors.With(errStdlib, "vm", "vm01"))
+	assert.Empty(t, errors.FieldsOf(errors.Wrap(joined, "some context")))
+}
+
+func TestError_Trace_fields(t *testing.T) {
+	t.Parallel()
+
+	var traced errors.Error
+
+	err := errors.Wrap(errors.With(errStdlib, "vm", "vm01"), "some context")
+	require.ErrorAs(t, err, &traced)
+
+	assert.Contains(t, traced.Trace(), "[vm=vm01]")
+}
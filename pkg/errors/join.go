@@ -0,0 +1,63 @@
+package errors
+
+import (
+	"strings"
+)
+
+// Errors multiple errors joined together, such as the failures from a bulk operation. Errors is compatible with
+// errors.Join, Is and As match any of the joined errors.
+type Errors []error
+
+// multiple error which joins several errors, such as Errors or the result of errors.Join.
+type multiple interface {
+	error
+	Unwrap() []error
+}
+
+// Join multiple errors, nil errors are discarded and nil is returned if every error is nil.
+func Join(errs ...error) error {
+	joined := make(Errors, 0, len(errs))
+
+	for _, err := range errs {
+		if err != nil {
+			joined = append(joined, err)
+		}
+	}
+
+	if len(joined) == 0 {
+		return nil
+	}
+
+	return joined
+}
+
+// Error provides the message of each joined error on a separate line.
+func (e Errors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Kind returns the kind shared by every joined error, or KindUnknown if kinds differ.
+func (e Errors) Kind() Kind {
+	kind := KindUnknown
+
+	for index, err := range e {
+		errKind := KindOf(err)
+		if index > 0 && errKind != kind {
+			return KindUnknown
+		}
+
+		kind = errKind
+	}
+
+	return kind
+}
+
+// Unwrap returns the joined errors.
+func (e Errors) Unwrap() []error {
+	return e
+}
@@ -0,0 +1,53 @@
+package errors_test
+
+import (
+	errs "errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+func TestJoin(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, errors.Join())
+	assert.NoError(t, errors.Join(nil, nil))
+
+	first := errors.NewKind(errors.KindNotFound, "virtual machine vm01 not found")
+	err := errors.Join(first, nil, errStdlib)
+	assert.Equal(t, "virtual machine vm01 not found\noriginal error", err.Error())
+	assert.True(t, errors.Is(err, first))
+	assert.True(t, errors.Is(err, errStdlib))
+
+	var joined errors.Errors
+	require.True(t, errors.As(err, &joined))
+	assert.Len(t, joined, 2)
+
+	stdlib := errs.Join(first, errStdlib)
+	assert.Equal(t, stdlib.Error(), err.Error())
+}
+
+func TestErrors_Kind(t *testing.T) {
+	t.Parallel()
+
+	notFound := errors.NewKind(errors.KindNotFound, "an error")
+	conflict := errors.NewKind(errors.KindConflict, "an error")
+
+	assert.Equal(t, errors.KindNotFound, errors.KindOf(errors.Join(notFound, errors.Wrap(notFound, "some context"))))
+	assert.Equal(t, errors.KindUnknown, errors.KindOf(errors.Join(notFound, conflict)))
+	assert.Equal(t, errors.KindUnknown, errors.KindOf(errors.Join(notFound, errStdlib)))
+}
+
+func TestDetail_joined(t *testing.T) {
+	t.Parallel()
+
+	err := errors.Wrap(errors.Join(
+		errors.Wrap(errors.NewKind(errors.KindNotFound, "virtual machine vm01 not found"), "some context"),
+		errors.New("unable to power on vm02"),
+	), "unable to power on virtual machines")
+
+	assert.Equal(t, "virtual machine vm01 not found; unable to power on vm02", errors.Detail(err))
+}
@@ -0,0 +1,76 @@
+package errors
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// encoded JSON representation of an error and the errors it wraps.
+type encoded struct {
+	Cause   *encoded       `json:"cause,omitempty"`
+	Errors  []encoded      `json:"errors,omitempty"`
+	Fields  map[string]any `json:"fields,omitempty"`
+	File    string         `json:"file,omitempty"`
+	Kind    Kind           `json:"kind,omitempty"`
+	Line    int            `json:"line,omitempty"`
+	Message string         `json:"message,omitempty"`
+}
+
+// MarshalJSON encodes an error with its caller information, kind and fields, and each error it wraps as the cause.
+func (e Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(encode(e))
+}
+
+// MarshalJSON encodes each joined error.
+func (e Errors) MarshalJSON() ([]byte, error) {
+	return json.Marshal(encode(e))
+}
+
+// MarshalText encodes a kind as its name.
+func (k Kind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// encode an error which may not be an Error.
+func encode(err error) encoded {
+	switch typed := err.(type) {
+	case Error:
+		value := encoded{
+			File:    typed.file,
+			Kind:    typed.kind,
+			Line:    typed.line,
+			Message: typed.message,
+		}
+
+		if len(typed.Fields()) > 0 {
+			value.Fields = make(map[string]any)
+			for _, field := range typed.Fields() {
+				value.Fields[field.Key] = field.Value
+			}
+		}
+
+		if typed.previous != nil {
+			cause := encode(typed.previous)
+			value.Cause = &cause
+		}
+
+		return value
+	case APIError:
+		return encoded{Kind: typed.Kind(), Message: typed.Error()}
+	case multiple:
+		value := encoded{Kind: KindOf(typed)}
+		for _, item := range typed.Unwrap() {
+			value.Errors = append(value.Errors, encode(item))
+		}
+
+		return value
+	}
+
+	value := encoded{Message: err.Error()}
+	if next := errors.Unwrap(err); next != nil {
+		cause := encode(next)
+		value.Cause = &cause
+	}
+
+	return value
+}
@@ -0,0 +1,101 @@
+package errors_test
+
+import (
+	"encoding/json"
+	errs "errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+// stripLocation remove caller information which depends on where tests are run.
+func stripLocation(value any) {
+	switch typed := value.(type) {
+	case map[string]any:
+		delete(typed, "file")
+		delete(typed, "line")
+
+		for _, nested := range typed {
+			stripLocation(nested)
+		}
+	case []any:
+		for _, nested := range typed {
+			stripLocation(nested)
+		}
+	}
+}
+
+func TestError_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	chain := errors.Wrap(
+		errors.With(
+			errors.Join(
+				errors.WrapKind(errors.APIError{Status: http.StatusNotFound, Type: "NOT_FOUND"}, errors.KindNotFound, "virtual machine not found"),
+				fmt.Errorf("some context: %w", errStdlib),
+			),
+			"action", "on",
+		),
+		"unable to power on virtual machines",
+	)
+
+	encoded, err := json.Marshal(chain)
+	require.NoError(t, err)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+	stripLocation(decoded)
+
+	expected := map[string]any{
+		"message": "unable to power on virtual machines",
+		"cause": map[string]any{
+			"fields": map[string]any{"action": "on"},
+			"cause": map[string]any{
+				"errors": []any{
+					map[string]any{
+						"kind":    "not_found",
+						"message": "virtual machine not found",
+						"cause": map[string]any{
+							"kind":    "not_found",
+							"message": "vsphere returned NOT_FOUND (404)",
+						},
+					},
+					map[string]any{
+						"message": "some context: original error",
+						"cause":   map[string]any{"message": "original error"},
+					},
+				},
+			},
+		},
+	}
+
+	assert.Equal(t, expected, decoded)
+}
+
+func TestError_MarshalJSON_location(t *testing.T) {
+	t.Parallel()
+
+	encoded, err := json.Marshal(errors.New("an error"))
+	require.NoError(t, err)
+
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(encoded, &decoded))
+
+	assert.Contains(t, decoded["file"], "json_test.go")
+	assert.NotZero(t, decoded["line"])
+	assert.NotContains(t, decoded, "kind")
+}
+
+func TestErrors_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	encoded, err := json.Marshal(errors.Join(errs.New("first"), errs.New("second")))
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"errors":[{"message":"first"},{"message":"second"}]}`, string(encoded))
+}
@@ -0,0 +1,174 @@
+package errors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+)
+
+// Kind classifies an error so callers can react to it without matching messages.
+type Kind int
+
+const (
+	// KindUnknown error which hasn't been classified.
+	KindUnknown Kind = iota
+	// KindConflict request conflicts with the current state of a resource.
+	KindConflict
+	// KindForbidden caller isn't permitted to perform a request.
+	KindForbidden
+	// KindNotFound resource doesn't exist.
+	KindNotFound
+	// KindNotImplemented upstream doesn't support a request.
+	KindNotImplemented
+	// KindTimeout upstream didn't respond in time.
+	KindTimeout
+	// KindUnauthenticated caller credentials are missing or were rejected.
+	KindUnauthenticated
+	// KindUnavailable request was rejected to protect an upstream which is failing or overloaded.
+	KindUnavailable
+	// KindUpstream upstream failed or couldn't be reached.
+	KindUpstream
+)
+
+// kinded error which has been classified.
+type kinded interface {
+	Kind() Kind
+}
+
+// Detail returns the message of the outermost classified error without the messages it wraps, falling back to the
+// outermost message if the error isn't classified.
+func Detail(err error) string {
+	var outermost string
+
+	for next := err; next != nil; next = errors.Unwrap(next) {
+		switch typed := next.(type) {
+		case APIError:
+			if typed.Message == "" {
+				return typed.Error()
+			}
+
+			return typed.Message
+		case Error:
+			if typed.kind != KindUnknown {
+				return typed.message
+			}
+
+			if outermost == "" {
+				outermost = typed.message
+			}
+		case Errors:
+			details := make([]string, 0, len(typed))
+			for _, item := range typed {
+				details = append(details, Detail(item))
+			}
+
+			return strings.Join(details, "; ")
+		case kinded:
+			if typed.Kind() != KindUnknown {
+				return next.Error()
+			}
+		}
+	}
+
+	return outermost
+}
+
+// KindOf returns the outermost kind found when unwrapping an error.
+func KindOf(err error) Kind {
+	for err != nil {
+		var classified kinded
+		if !errors.As(err, &classified) {
+			return KindUnknown
+		}
+
+		kind := classified.Kind()
+		if kind != KindUnknown {
+			return kind
+		}
+
+		err = errors.Unwrap(classified.(error))
+	}
+
+	return KindUnknown
+}
+
+// NewKind creates a new error message with a kind.
+func NewKind(kind Kind, message any, replacements ...any) error {
+	_, file, line, _ := runtime.Caller(1)
+
+	return Error{
+		file:     file,
+		kind:     kind,
+		line:     line,
+		message:  fmt.Sprintf(fmt.Sprintf("%v", message), replacements...),
+		previous: nil,
+	}
+}
+
+// WrapKind wraps an error with additional context and a kind.
+func WrapKind(err error, kind Kind, context any, replacements ...any) error {
+	_, file, line, _ := runtime.Caller(1)
+
+	return Error{
+		file:     file,
+		kind:     kind,
+		line:     line,
+		message:  fmt.Sprintf(fmt.Sprintf("%v", context), replacements...),
+		previous: err,
+	}
+}
+
+// Kind returns the kind an error was created with.
+func (e Error) Kind() Kind {
+	return e.kind
+}
+
+// Status http status code which represents a kind.
+func (k Kind) Status() int {
+	switch k {
+	case KindConflict:
+		return http.StatusConflict
+	case KindForbidden:
+		return http.StatusForbidden
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindNotImplemented:
+		return http.StatusNotImplemented
+	case KindTimeout:
+		return http.StatusGatewayTimeout
+	case KindUnauthenticated:
+		return http.StatusUnauthorized
+	case KindUnavailable:
+		return http.StatusServiceUnavailable
+	case KindUpstream:
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// String name of a kind.
+func (k Kind) String() string {
+	switch k {
+	case KindConflict:
+		return "conflict"
+	case KindForbidden:
+		return "forbidden"
+	case KindNotFound:
+		return "not_found"
+	case KindNotImplemented:
+		return "not_implemented"
+	case KindTimeout:
+		return "timeout"
+	case KindUnauthenticated:
+		return "unauthenticated"
+	case KindUnavailable:
+		return "unavailable"
+	case KindUpstream:
+		return "upstream"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,43 @@
+package errors
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errKindPackage = NewKind(KindNotFound, "original error")
+
+func TestNewKind(t *testing.T) {
+	t.Parallel()
+
+	_, file, _, ok := runtime.Caller(0)
+	require.True(t, ok)
+
+	expected := Error{
+		file:     file,
+		kind:     KindNotFound,
+		line:     11,
+		message:  "original error",
+		previous: nil,
+	}
+	assert.Equal(t, expected, errKindPackage)
+}
+
+func TestWrapKind(t *testing.T) {
+	t.Parallel()
+
+	_, file, _, ok := runtime.Caller(0)
+	require.True(t, ok)
+
+	expected := Error{
+		file:     file,
+		kind:     KindUpstream,
+		line:     42,
+		message:  "some context",
+		previous: errKindPackage,
+	}
+	assert.Equal(t, expected, WrapKind(errKindPackage, KindUpstream, "some context"))
+}
@@ -0,0 +1,142 @@
+package errors_test
+
+import (
+	errs "errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+func TestDetail(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		err      error
+		expected string
+	}{
+		"nil": {
+			err:      nil,
+			expected: "",
+		},
+		"stdlib": {
+			err:      errs.New("an error"),
+			expected: "",
+		},
+		"unclassified": {
+			err:      errors.Wrap(errs.New("an error"), "some context"),
+			expected: "some context",
+		},
+		"classified": {
+			err:      errors.Wrap(errors.NewKind(errors.KindNotFound, "virtual machine not found"), "some context"),
+			expected: "virtual machine not found",
+		},
+		"api error": {
+			err:      errors.Wrap(errors.APIError{Message: "already powered on", Status: http.StatusBadRequest}, "some context"),
+			expected: "already powered on",
+		},
+		"classified type": {
+			err:      errors.Wrap(classified{}, "some context"),
+			expected: "classified error",
+		},
+		"api error without message": {
+			err:      errors.APIError{Status: http.StatusServiceUnavailable},
+			expected: "vsphere returned Service Unavailable (503)",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, testCase.expected, errors.Detail(testCase.err))
+		})
+	}
+}
+
+// classified error type which isn't an errors.Error.
+type classified struct{}
+
+func (classified) Error() string {
+	return "classified error"
+}
+
+func (classified) Kind() errors.Kind {
+	return errors.KindUnavailable
+}
+
+func TestKindOf(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		err      error
+		expected errors.Kind
+	}{
+		"nil": {
+			err:      nil,
+			expected: errors.KindUnknown,
+		},
+		"stdlib": {
+			err:      errs.New("an error"),
+			expected: errors.KindUnknown,
+		},
+		"unclassified": {
+			err:      errors.New("an error"),
+			expected: errors.KindUnknown,
+		},
+		"classified": {
+			err:      errors.NewKind(errors.KindNotFound, "an error"),
+			expected: errors.KindNotFound,
+		},
+		"wrapped": {
+			err:      errors.Wrap(errors.NewKind(errors.KindConflict, "an error"), "some context"),
+			expected: errors.KindConflict,
+		},
+		"outermost": {
+			err:      errors.WrapKind(errors.NewKind(errors.KindUnauthenticated, "an error"), errors.KindUpstream, "some context"),
+			expected: errors.KindUpstream,
+		},
+		"stdlib wrapped": {
+			err:      fmt.Errorf("some context: %w", errors.NewKind(errors.KindTimeout, "an error")),
+			expected: errors.KindTimeout,
+		},
+		"api error": {
+			err:      errors.Wrap(errors.APIError{Status: http.StatusNotFound, Type: "NOT_FOUND"}, "some context"),
+			expected: errors.KindNotFound,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, testCase.expected, errors.KindOf(testCase.err))
+		})
+	}
+}
+
+func TestKind_Status(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, http.StatusInternalServerError, errors.KindUnknown.Status())
+	assert.Equal(t, http.StatusConflict, errors.KindConflict.Status())
+	assert.Equal(t, http.StatusForbidden, errors.KindForbidden.Status())
+	assert.Equal(t, http.StatusNotFound, errors.KindNotFound.Status())
+	assert.Equal(t, http.StatusNotImplemented, errors.KindNotImplemented.Status())
+	assert.Equal(t, http.StatusGatewayTimeout, errors.KindTimeout.Status())
+	assert.Equal(t, http.StatusUnauthorized, errors.KindUnauthenticated.Status())
+	assert.Equal(t, http.StatusServiceUnavailable, errors.KindUnavailable.Status())
+	assert.Equal(t, http.StatusBadGateway, errors.KindUpstream.Status())
+}
+
+func TestKind_String(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "unknown", errors.KindUnknown.String())
+	assert.Equal(t, "not_found", errors.KindNotFound.String())
+	assert.Equal(t, "not_implemented", errors.KindNotImplemented.String())
+	assert.Equal(t, "upstream", errors.KindUpstream.String())
+}
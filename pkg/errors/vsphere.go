@@ -0,0 +1,126 @@
+package errors
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError error response returned by the vsphere api.
+type APIError struct {
+	Message string
+	Status  int
+	Type    string
+}
+
+// apiResponse vsphere error body, /api returns error_type while the deprecated /rest api returns a namespaced type.
+type apiResponse struct {
+	ErrorType string       `json:"error_type"`
+	Messages  []apiMessage `json:"messages"`
+	Type      string       `json:"type"`
+	Value     struct {
+		Messages []apiMessage `json:"messages"`
+	} `json:"value"`
+}
+
+// apiMessage localizable message within a vsphere error body.
+type apiMessage struct {
+	DefaultMessage string `json:"default_message"`
+}
+
+// ParseAPIError creates an APIError from a vsphere api response.
+func ParseAPIError(status int, body []byte) APIError {
+	apiError := APIError{
+		Message: strings.TrimSpace(string(body)),
+		Status:  status,
+	}
+
+	var response apiResponse
+	if json.Unmarshal(body, &response) != nil {
+		return apiError
+	}
+
+	apiError.Type = errorType(response)
+
+	messages := response.Messages
+	if len(messages) == 0 {
+		messages = response.Value.Messages
+	}
+
+	if len(messages) > 0 {
+		defaults := make([]string, 0, len(messages))
+		for _, message := range messages {
+			defaults = append(defaults, message.DefaultMessage)
+		}
+
+		apiError.Message = strings.Join(defaults, ", ")
+	}
+
+	return apiError
+}
+
+// Error provides the vsphere error type and message.
+func (e APIError) Error() string {
+	description := http.StatusText(e.Status)
+	if e.Type != "" {
+		description = e.Type
+	}
+
+	if e.Message == "" {
+		return fmt.Sprintf("vsphere returned %s (%d)", description, e.Status)
+	}
+
+	return fmt.Sprintf("vsphere returned %s (%d): %s", description, e.Status, e.Message)
+}
+
+// Kind classifies the vsphere error type, falling back to the status code if the type isn't known.
+func (e APIError) Kind() Kind {
+	switch e.Type {
+	case "ALREADY_EXISTS", "ALREADY_IN_DESIRED_STATE", "CONCURRENT_CHANGE", "NOT_ALLOWED_IN_CURRENT_STATE",
+		"RESOURCE_BUSY", "RESOURCE_IN_USE":
+		return KindConflict
+	case "NOT_FOUND":
+		return KindNotFound
+	case "TIMED_OUT":
+		return KindTimeout
+	case "UNAUTHENTICATED":
+		return KindUnauthenticated
+	case "UNAUTHORIZED":
+		return KindForbidden
+	case "UNSUPPORTED":
+		return KindNotImplemented
+	}
+
+	switch e.Status {
+	case http.StatusConflict:
+		return KindConflict
+	case http.StatusForbidden:
+		return KindForbidden
+	case http.StatusGatewayTimeout:
+		return KindTimeout
+	case http.StatusNotFound:
+		return KindNotFound
+	case http.StatusNotImplemented:
+		return KindNotImplemented
+	case http.StatusUnauthorized:
+		return KindUnauthenticated
+	default:
+		return KindUpstream
+	}
+}
+
+// errorType normalise the type of a vsphere error body, e.g. com.vmware.vapi.std.errors.not_found becomes NOT_FOUND.
+func errorType(response apiResponse) string {
+	if response.ErrorType != "" {
+		return response.ErrorType
+	}
+
+	if response.Type == "" {
+		return ""
+	}
+
+	segments := strings.Split(response.Type, ".")
+
+	return strings.ToUpper(segments[len(segments)-1])
+}
@@ -0,0 +1,15 @@
+package errors
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_errorType(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "NOT_FOUND", errorType(apiResponse{ErrorType: "NOT_FOUND", Type: "com.vmware.vapi.std.errors.unauthenticated"}))
+	assert.Equal(t, "RESOURCE_BUSY", errorType(apiResponse{Type: "com.vmware.vapi.std.errors.resource_busy"}))
+	assert.Equal(t, "", errorType(apiResponse{}))
+}
@@ -0,0 +1,125 @@
+package errors_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+func TestParseAPIError(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		body     string
+		expected errors.APIError
+		status   int
+	}{
+		"api": {
+			body:     `{"error_type":"NOT_FOUND","messages":[{"args":[],"default_message":"Virtual machine not found.","id":"vm.not_found"}]}`,
+			expected: errors.APIError{Message: "Virtual machine not found.", Status: http.StatusNotFound, Type: "NOT_FOUND"},
+			status:   http.StatusNotFound,
+		},
+		"rest": {
+			body:     `{"type":"com.vmware.vapi.std.errors.already_in_desired_state","value":{"messages":[{"default_message":"Virtual machine is already powered on."}]}}`,
+			expected: errors.APIError{Message: "Virtual machine is already powered on.", Status: http.StatusBadRequest, Type: "ALREADY_IN_DESIRED_STATE"},
+			status:   http.StatusBadRequest,
+		},
+		"multiple messages": {
+			body:     `{"error_type":"RESOURCE_BUSY","messages":[{"default_message":"first"},{"default_message":"second"}]}`,
+			expected: errors.APIError{Message: "first, second", Status: http.StatusBadRequest, Type: "RESOURCE_BUSY"},
+			status:   http.StatusBadRequest,
+		},
+		"not json": {
+			body:     "service unavailable\n",
+			expected: errors.APIError{Message: "service unavailable", Status: http.StatusServiceUnavailable},
+			status:   http.StatusServiceUnavailable,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, testCase.expected, errors.ParseAPIError(testCase.status, []byte(testCase.body)))
+		})
+	}
+}
+
+func TestAPIError_Error(t *testing.T) {
+	t.Parallel()
+
+	err := errors.APIError{Message: "Virtual machine not found.", Status: http.StatusNotFound, Type: "NOT_FOUND"}
+	assert.Equal(t, "vsphere returned NOT_FOUND (404): Virtual machine not found.", err.Error())
+
+	err = errors.APIError{Status: http.StatusServiceUnavailable}
+	assert.Equal(t, "vsphere returned Service Unavailable (503)", err.Error())
+}
+
+func TestAPIError_Kind(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		err      errors.APIError
+		expected errors.Kind
+	}{
+		"already in desired state": {
+			err:      errors.APIError{Status: http.StatusBadRequest, Type: "ALREADY_IN_DESIRED_STATE"},
+			expected: errors.KindConflict,
+		},
+		"resource busy": {
+			err:      errors.APIError{Status: http.StatusBadRequest, Type: "RESOURCE_BUSY"},
+			expected: errors.KindConflict,
+		},
+		"not found": {
+			err:      errors.APIError{Status: http.StatusNotFound, Type: "NOT_FOUND"},
+			expected: errors.KindNotFound,
+		},
+		"timed out": {
+			err:      errors.APIError{Status: http.StatusInternalServerError, Type: "TIMED_OUT"},
+			expected: errors.KindTimeout,
+		},
+		"unauthenticated": {
+			err:      errors.APIError{Status: http.StatusUnauthorized, Type: "UNAUTHENTICATED"},
+			expected: errors.KindUnauthenticated,
+		},
+		"unauthorized": {
+			err:      errors.APIError{Status: http.StatusForbidden, Type: "UNAUTHORIZED"},
+			expected: errors.KindForbidden,
+		},
+		"untyped unauthorized": {
+			err:      errors.APIError{Status: http.StatusUnauthorized},
+			expected: errors.KindUnauthenticated,
+		},
+		"unsupported": {
+			err:      errors.APIError{Status: http.StatusBadRequest, Type: "UNSUPPORTED"},
+			expected: errors.KindNotImplemented,
+		},
+		"untyped not implemented": {
+			err:      errors.APIError{Status: http.StatusNotImplemented},
+			expected: errors.KindNotImplemented,
+		},
+		"untyped gateway timeout": {
+			err:      errors.APIError{Status: http.StatusGatewayTimeout},
+			expected: errors.KindTimeout,
+		},
+		"service unavailable": {
+			err:      errors.APIError{Status: http.StatusServiceUnavailable, Type: "SERVICE_UNAVAILABLE"},
+			expected: errors.KindUpstream,
+		},
+		"invalid argument": {
+			err:      errors.APIError{Status: http.StatusBadRequest, Type: "INVALID_ARGUMENT"},
+			expected: errors.KindUpstream,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, testCase.expected, testCase.err.Kind())
+		})
+	}
+}
@@ -3,12 +3,14 @@ package notifier
 import (
 	"log"
 	"strings"
+	"sync"
 
 	"github.com/containrrr/shoutrrr"
 )
 
 type Notifier struct {
-	urls []string
+	mutex sync.RWMutex
+	urls  []string
 }
 
 func New(urls []string) *Notifier {
@@ -18,7 +20,11 @@ func New(urls []string) *Notifier {
 }
 
 func (n *Notifier) Message(text string) {
-	for _, url := range n.urls {
+	n.mutex.RLock()
+	urls := n.urls
+	n.mutex.RUnlock()
+
+	for _, url := range urls {
 		url = strings.TrimSpace(url)
 
 		if url == "" {
@@ -31,3 +37,10 @@ func (n *Notifier) Message(text string) {
 		}
 	}
 }
+
+func (n *Notifier) Update(urls []string) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	n.urls = urls
+}
@@ -0,0 +1,148 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/errors"
+)
+
+const (
+	// Ready tell the service manager startup is complete.
+	Ready = "READY=1"
+
+	// Stopping tell the service manager the service is shutting down.
+	Stopping = "STOPPING=1"
+
+	// Watchdog tell the service manager the service is healthy.
+	Watchdog = "WATCHDOG=1"
+
+	// listenFdsStart first file descriptor passed by the service manager.
+	listenFdsStart = 3
+)
+
+// Listener socket passed by the service manager.
+type Listener struct {
+	Listener net.Listener
+	Name     string
+}
+
+// Listeners get sockets passed by the service manager for socket activation, nil if none were passed.
+func Listeners() ([]Listener, error) {
+	defer func() {
+		// Sockets must not be inherited by child processes
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	count, err := listenFds(os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getpid())
+	if err != nil || count == 0 {
+		return nil, err
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	listeners := make([]Listener, 0, count)
+
+	for index := range count {
+		descriptor := listenFdsStart + index
+
+		name := "LISTEN_FD_" + strconv.Itoa(descriptor)
+		if index < len(names) && names[index] != "" {
+			name = names[index]
+		}
+
+		file := os.NewFile(uintptr(descriptor), name)
+
+		listener, err := net.FileListener(file)
+		_ = file.Close()
+
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to use socket %s", name)
+		}
+
+		listeners = append(listeners, Listener{Listener: listener, Name: name})
+	}
+
+	return listeners, nil
+}
+
+// Notify send a state to the service manager, returns false if the service isn't run by a service manager.
+func Notify(state string) (bool, error) {
+	return notify(os.Getenv("NOTIFY_SOCKET"), state)
+}
+
+// WatchdogInterval get the interval watchdog notifications must be sent at, returns false if the watchdog isn't enabled.
+func WatchdogInterval() (time.Duration, bool) {
+	return watchdogInterval(os.Getenv("WATCHDOG_USEC"), os.Getenv("WATCHDOG_PID"), os.Getpid())
+}
+
+// listenFds get the number of sockets passed to a process.
+func listenFds(pid string, fds string, self int) (int, error) {
+	if pid == "" || fds == "" {
+		return 0, nil
+	}
+
+	listenPid, err := strconv.Atoi(pid)
+	if err != nil {
+		return 0, errors.Wrap(err, "invalid LISTEN_PID %s", pid)
+	}
+
+	// Sockets were passed to another process
+	if listenPid != self {
+		return 0, nil
+	}
+
+	count, err := strconv.Atoi(fds)
+	if err != nil || count < 0 {
+		return 0, errors.New("invalid LISTEN_FDS %s", fds)
+	}
+
+	return count, nil
+}
+
+// notify send a state to a notify socket.
+func notify(socket string, state string) (bool, error) {
+	if socket == "" {
+		return false, nil
+	}
+
+	// Abstract sockets are prefixed with @ in the environment and a null byte on the wire
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+
+	connection, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, errors.Wrap(err, "unable to connect to notify socket")
+	}
+	defer func() { _ = connection.Close() }()
+
+	_, err = connection.Write([]byte(state))
+	if err != nil {
+		return false, errors.Wrap(err, "unable to send notification")
+	}
+
+	return true, nil
+}
+
+// watchdogInterval get half the watchdog timeout so notifications arrive before it expires.
+func watchdogInterval(usec string, pid string, self int) (time.Duration, bool) {
+	if usec == "" {
+		return 0, false
+	}
+
+	if pid != "" && pid != strconv.Itoa(self) {
+		return 0, false
+	}
+
+	microseconds, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || microseconds <= 0 {
+		return 0, false
+	}
+
+	return time.Duration(microseconds) * time.Microsecond / 2, true
+}
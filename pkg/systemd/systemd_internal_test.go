@@ -0,0 +1,142 @@
+package systemd
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenFds(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		pid      string
+		fds      string
+		expected int
+		err      bool
+	}{
+		"unset": {
+			expected: 0,
+		},
+		"other process": {
+			pid:      "200",
+			fds:      "2",
+			expected: 0,
+		},
+		"this process": {
+			pid:      "100",
+			fds:      "2",
+			expected: 2,
+		},
+		"invalid pid": {
+			pid: "abc",
+			fds: "2",
+			err: true,
+		},
+		"invalid fds": {
+			pid: "100",
+			fds: "-1",
+			err: true,
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			count, err := listenFds(testcase.pid, testcase.fds, 100)
+			if testcase.err {
+				require.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testcase.expected, count)
+		})
+	}
+}
+
+func TestNotify(t *testing.T) {
+	t.Parallel()
+
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+
+	connection, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+
+	defer func() { _ = connection.Close() }()
+
+	sent, err := notify(socket, Ready)
+	require.NoError(t, err)
+	assert.True(t, sent)
+
+	buffer := make([]byte, 64)
+	read, err := connection.Read(buffer)
+	require.NoError(t, err)
+	assert.Equal(t, Ready, string(buffer[:read]))
+}
+
+func TestNotify_NoSocket(t *testing.T) {
+	t.Parallel()
+
+	sent, err := notify("", Ready)
+	require.NoError(t, err)
+	assert.False(t, sent)
+}
+
+func TestNotify_MissingSocket(t *testing.T) {
+	t.Parallel()
+
+	sent, err := notify(filepath.Join(t.TempDir(), "missing.sock"), Ready)
+	require.Error(t, err)
+	assert.False(t, sent)
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Parallel()
+
+	testcases := map[string]struct {
+		usec     string
+		pid      string
+		expected time.Duration
+		enabled  bool
+	}{
+		"unset": {},
+		"enabled": {
+			usec:     "30000000",
+			expected: 15 * time.Second,
+			enabled:  true,
+		},
+		"this process": {
+			usec:     "10000000",
+			pid:      "100",
+			expected: 5 * time.Second,
+			enabled:  true,
+		},
+		"other process": {
+			usec: "10000000",
+			pid:  "200",
+		},
+		"invalid": {
+			usec: "abc",
+		},
+		"zero": {
+			usec: "0",
+		},
+	}
+
+	for name, testcase := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			interval, enabled := watchdogInterval(testcase.usec, testcase.pid, 100)
+			assert.Equal(t, testcase.enabled, enabled)
+			assert.Equal(t, testcase.expected, interval)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package systemd_test
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/sjdaws/vsphere-bridge/pkg/systemd"
+)
+
+func TestListeners(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+
+	listeners, err := systemd.Listeners()
+	require.NoError(t, err)
+	assert.Nil(t, listeners)
+
+	_, ok := os.LookupEnv("LISTEN_FDS")
+	assert.False(t, ok)
+}
+
+func TestNotify(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+
+	connection, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+
+	defer func() { _ = connection.Close() }()
+
+	t.Setenv("NOTIFY_SOCKET", socket)
+
+	sent, err := systemd.Notify(systemd.Stopping)
+	require.NoError(t, err)
+	assert.True(t, sent)
+
+	buffer := make([]byte, 64)
+	read, err := connection.Read(buffer)
+	require.NoError(t, err)
+	assert.Equal(t, systemd.Stopping, string(buffer[:read]))
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	t.Setenv("WATCHDOG_USEC", "4000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+
+	interval, enabled := systemd.WatchdogInterval()
+	assert.True(t, enabled)
+	assert.Equal(t, 2*time.Second, interval)
+}